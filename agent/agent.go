@@ -80,8 +80,6 @@ func Parse(db *database.Database, r *http.Request) (agnt *Agent, err error) {
 		return
 	}
 
-	client := parser.Parse(r.UserAgent())
-
 	ip := node.Self.GetRemoteAddr(r)
 
 	ge, err := geo.Get(db, ip)
@@ -93,6 +91,27 @@ func Parse(db *database.Database, r *http.Request) (agnt *Agent, err error) {
 		return
 	}
 
+	agnt = newAgent(ip, ge, r.UserAgent())
+
+	return
+}
+
+func Simulate(db *database.Database, ip, userAgent string) (
+	agnt *Agent, err error) {
+
+	ge, err := geo.Lookup(db, ip)
+	if err != nil {
+		return
+	}
+
+	agnt = newAgent(ip, ge, userAgent)
+
+	return
+}
+
+func newAgent(ip string, ge *geo.Geo, userAgent string) (agnt *Agent) {
+	client := parser.Parse(userAgent)
+
 	agnt = &Agent{
 		Ip:            ip,
 		Isp:           ge.Isp,
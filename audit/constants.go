@@ -20,6 +20,7 @@ const (
 	ProxyDeviceApprove         = "proxy_device_approve"
 	ProxyDeviceRegisterRequest = "proxy_device_register_request"
 	ProxyDeviceRegister        = "proxy_device_register"
+	ProxyStepUp                = "proxy_step_up"
 
 	UserLogin                 = "user_login"
 	UserLoginFailed           = "user_login_failed"
@@ -43,4 +44,14 @@ const (
 	OktaDeny             = "okta_deny"
 	SshApprove           = "ssh_approve"
 	SshDeny              = "ssh_deny"
+	SshApprovalRequest   = "ssh_approval_request"
+	SshApprovalApprove   = "ssh_approval_approve"
+	SshApprovalDeny      = "ssh_approval_deny"
+	SchedulePolicyDeny   = "schedule_policy_deny"
+	PolicyMonitor        = "policy_monitor"
+
+	BreakGlassSeal         = "break_glass_seal"
+	BreakGlassUnlock       = "break_glass_unlock"
+	BreakGlassUnlockFailed = "break_glass_unlock_failed"
+	BreakGlassLock         = "break_glass_lock"
 )
@@ -0,0 +1,71 @@
+package authority
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+func (a *Authority) GetApprovalExpire() int {
+	if a.ApprovalExpire == 0 {
+		return DefaultApprovalExpire
+	}
+	return a.ApprovalExpire
+}
+
+func (a *Authority) ApproverHasAccess(usr *user.User) bool {
+	if !a.ApprovalRequired {
+		return true
+	}
+	return usr.RolesMatch(a.ApprovalRoles)
+}
+
+func (a *Authority) validateApproval() (errData *errortypes.ErrorData) {
+	if !a.ApprovalRequired {
+		a.ApprovalRoles = []string{}
+		a.ApprovalExpire = 0
+		return
+	}
+
+	roles := []string{}
+	rolesSet := set.NewSet()
+
+	for _, role := range a.ApprovalRoles {
+		role = strings.TrimSpace(role)
+		if role == "" || rolesSet.Contains(role) {
+			continue
+		}
+
+		rolesSet.Add(role)
+		roles = append(roles, role)
+	}
+
+	sort.Strings(roles)
+	a.ApprovalRoles = roles
+
+	if len(a.ApprovalRoles) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_roles_missing",
+			Message: "Approver roles required when approval is enabled",
+		}
+		return
+	}
+
+	if a.ApprovalExpire == 0 {
+		a.ApprovalExpire = DefaultApprovalExpire
+	}
+
+	if a.ApprovalExpire < 1 || a.ApprovalExpire > 1440 {
+		errData = &errortypes.ErrorData{
+			Error: "approval_expire_invalid",
+			Message: "Approval window must be " +
+				"between 1 and 1440 minutes",
+		}
+		return
+	}
+
+	return
+}
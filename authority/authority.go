@@ -1,13 +1,13 @@
 package authority
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/subtle"
 	"crypto/tls"
@@ -17,20 +17,21 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
-	"hash/fnv"
 	"math/big"
 	"net"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/agent"
 	"github.com/pritunl/pritunl-zero/database"
 	"github.com/pritunl/pritunl-zero/errortypes"
 	"github.com/pritunl/pritunl-zero/event"
@@ -58,58 +59,82 @@ type Info struct {
 }
 
 type Authority struct {
-	Id                 primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name               string             `bson:"name" json:"name"`
-	Type               string             `bson:"type" json:"type"`
-	Info               *Info              `bson:"info" json:"info"`
-	MatchRoles         bool               `bson:"match_roles" json:"match_roles"`
-	Roles              []string           `bson:"roles" json:"roles"`
-	Expire             int                `bson:"expire" json:"expire"`
-	HostExpire         int                `bson:"host_expire" json:"host_expire"`
-	Algorithm          string             `bson:"algorithm" json:"algorithm"`
-	PrivateKey         string             `bson:"private_key" json:"-"`
-	PublicKey          string             `bson:"public_key" json:"public_key"`
-	PublicKeyPem       string             `bson:"public_key_pem" json:"public_key_pem"`
-	RootCertificate    string             `bson:"root_certificate" json:"root_certificate"`
-	ProxyJump          string             `bson:"-" json:"proxy_jump"`
-	ProxyPrivateKey    string             `bson:"proxy_private_key" json:"-"`
-	ProxyPublicKey     string             `bson:"proxy_public_key" json:"proxy_public_key"`
-	ProxyHosting       bool               `bson:"proxy_hosting" json:"proxy_hosting"`
-	ProxyHostname      string             `bson:"proxy_hostname" json:"proxy_hostname"`
-	ProxyPort          int                `bson:"proxy_port" json:"proxy_port"`
-	HostDomain         string             `bson:"host_domain" json:"host_domain"`
-	HostSubnets        []string           `bson:"host_subnets" json:"host_subnets"`
-	HostMatches        []string           `bson:"host_matches" json:"host_matches"`
-	HostProxy          string             `bson:"host_proxy" json:"host_proxy"`
-	HostCertificates   bool               `bson:"host_certificates" json:"host_certificates"`
-	StrictHostChecking bool               `bson:"strict_host_checking" json:"strict_host_checking"`
-	HostTokens         []string           `bson:"host_tokens" json:"host_tokens"`
-	HsmToken           string             `bson:"hsm_token" json:"hsm_token"`
-	HsmSecret          string             `bson:"hsm_secret" json:"hsm_secret"`
-	HsmSerial          string             `bson:"hsm_serial" json:"hsm_serial"`
-	HsmStatus          string             `bson:"hsm_status" json:"hsm_status"`
-	HsmTimestamp       time.Time          `bson:"hsm_timestamp" json:"hsm_timestamp"`
+	Id                 primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Name               string              `bson:"name" json:"name"`
+	Type               string              `bson:"type" json:"type"`
+	Info               *Info               `bson:"info" json:"info"`
+	MatchRoles         bool                `bson:"match_roles" json:"match_roles"`
+	Roles              []string            `bson:"roles" json:"roles"`
+	Expire             int                 `bson:"expire" json:"expire"`
+	RoleExpires        []*RoleExpire       `bson:"role_expires" json:"role_expires"`
+	HostExpire         int                 `bson:"host_expire" json:"host_expire"`
+	Algorithm          string              `bson:"algorithm" json:"algorithm"`
+	PrivateKey         string              `bson:"private_key" json:"-"`
+	PublicKey          string              `bson:"public_key" json:"public_key"`
+	PublicKeyPem       string              `bson:"public_key_pem" json:"public_key_pem"`
+	RotatedKeys        []*RotatedKey       `bson:"rotated_keys" json:"rotated_keys"`
+	KeyOverlap         int                 `bson:"key_overlap" json:"key_overlap"`
+	RootCertificate    string              `bson:"root_certificate" json:"root_certificate"`
+	ProxyJump          string              `bson:"-" json:"proxy_jump"`
+	ProxyPrivateKey    string              `bson:"proxy_private_key" json:"-"`
+	ProxyPublicKey     string              `bson:"proxy_public_key" json:"proxy_public_key"`
+	ProxyHosting       bool                `bson:"proxy_hosting" json:"proxy_hosting"`
+	ProxyHostname      string              `bson:"proxy_hostname" json:"proxy_hostname"`
+	ProxyPort          int                 `bson:"proxy_port" json:"proxy_port"`
+	HostDomain         string              `bson:"host_domain" json:"host_domain"`
+	HostSubnets        []string            `bson:"host_subnets" json:"host_subnets"`
+	HostMatches        []string            `bson:"host_matches" json:"host_matches"`
+	HostProxy          string              `bson:"host_proxy" json:"host_proxy"`
+	HostValidation     string              `bson:"host_validation" json:"host_validation"`
+	HostCertificates   bool                `bson:"host_certificates" json:"host_certificates"`
+	StrictHostChecking bool                `bson:"strict_host_checking" json:"strict_host_checking"`
+	CertificateProfile *CertificateProfile `bson:"certificate_profile" json:"certificate_profile"`
+	PrincipalTemplates []string            `bson:"principal_templates" json:"principal_templates"`
+	PrincipalMappings  []*PrincipalMapping `bson:"principal_mappings" json:"principal_mappings"`
+	ApprovalRequired   bool                `bson:"approval_required" json:"approval_required"`
+	ApprovalRoles      []string            `bson:"approval_roles" json:"approval_roles"`
+	ApprovalExpire     int                 `bson:"approval_expire" json:"approval_expire"`
+	HostTokens         []string            `bson:"host_tokens" json:"host_tokens"`
+	HsmToken           string              `bson:"hsm_token" json:"hsm_token"`
+	HsmSecret          string              `bson:"hsm_secret" json:"hsm_secret"`
+	HsmSerial          string              `bson:"hsm_serial" json:"hsm_serial"`
+	HsmStatus          string              `bson:"hsm_status" json:"hsm_status"`
+	HsmTimestamp       time.Time           `bson:"hsm_timestamp" json:"hsm_timestamp"`
+	BastionStatus      string              `bson:"bastion_status" json:"bastion_status"`
+	BastionRestarts    int                 `bson:"bastion_restarts" json:"bastion_restarts"`
+	BastionError       string              `bson:"bastion_error" json:"bastion_error"`
+	BastionTimestamp   time.Time           `bson:"bastion_timestamp" json:"bastion_timestamp"`
+	Pkcs11Module       string              `bson:"pkcs11_module" json:"pkcs11_module"`
+	Pkcs11Token        string              `bson:"pkcs11_token" json:"pkcs11_token"`
+	Pkcs11Key          string              `bson:"pkcs11_key" json:"pkcs11_key"`
 }
 
-func (a *Authority) GetDomain(hostname string) string {
-	return hostname + "." + a.HostDomain
-}
+func (a *Authority) getPrivateKey() (key crypto.Signer, err error) {
+	if a.Type == Pkcs11 {
+		key, err = a.getPkcs11Signer()
+		return
+	}
 
-func (a *Authority) GenerateRsaProxyPrivateKey() (err error) {
-	privKeyBytes, pubKeyBytes, err := GenerateRsaKey()
+	privateKey, err := ParsePemKey(a.PrivateKey)
 	if err != nil {
 		return
 	}
 
-	a.Info = &Info{
-		KeyAlg: "RSA 4096",
+	key, ok := privateKey.(crypto.Signer)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("authority: Unsupported private key type"),
+		}
+		return
 	}
-	a.ProxyPrivateKey = strings.TrimSpace(string(privKeyBytes))
-	a.ProxyPublicKey = strings.TrimSpace(string(pubKeyBytes))
 
 	return
 }
 
+func (a *Authority) GetDomain(hostname string) string {
+	return hostname + "." + a.HostDomain
+}
+
 func (a *Authority) GenerateRsaPrivateKey() (err error) {
 	privKeyBytes, pubKeyBytes, err := GenerateRsaKey()
 	if err != nil {
@@ -150,16 +175,65 @@ func (a *Authority) GenerateEcPrivateKey() (err error) {
 	return
 }
 
+func (a *Authority) GenerateEd25519PrivateKey() (err error) {
+	privKeyBytes, pubKeyBytes, err := GenerateEd25519Key()
+	if err != nil {
+		return
+	}
+
+	a.Info = &Info{
+		KeyAlg: "Ed25519",
+	}
+	a.PrivateKey = strings.TrimSpace(string(privKeyBytes))
+	a.PublicKey = strings.TrimSpace(string(pubKeyBytes))
+
+	err = a.SetPublicKeyPem()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func (a *Authority) GeneratePrivateKey() (err error) {
-	if a.Algorithm == ECP384 {
+	switch a.Algorithm {
+	case ECP384:
 		err = a.GenerateEcPrivateKey()
-	} else {
+		break
+	case ED25519:
+		err = a.GenerateEd25519PrivateKey()
+		break
+	default:
 		err = a.GenerateRsaPrivateKey()
 	}
 
 	return
 }
 
+func (a *Authority) GenerateProxyPrivateKey() (err error) {
+	var privKeyBytes []byte
+	var pubKeyBytes []byte
+
+	switch a.Algorithm {
+	case ECP384:
+		privKeyBytes, pubKeyBytes, err = GenerateEcKey()
+		break
+	case ED25519:
+		privKeyBytes, pubKeyBytes, err = GenerateEd25519Key()
+		break
+	default:
+		privKeyBytes, pubKeyBytes, err = GenerateRsaKey()
+	}
+	if err != nil {
+		return
+	}
+
+	a.ProxyPrivateKey = strings.TrimSpace(string(privKeyBytes))
+	a.ProxyPublicKey = strings.TrimSpace(string(pubKeyBytes))
+
+	return
+}
+
 func (a *Authority) GenerateHsmToken() (err error) {
 	a.PublicKey = ""
 
@@ -202,20 +276,42 @@ func (a *Authority) GetBastionDomain() string {
 	return hostProxy[0]
 }
 
-func (a *Authority) GetCertAuthority() string {
+func (a *Authority) GetCertAuthority() (certAuthrs []string) {
+	certAuthrs = []string{}
+
 	if a.HostDomain == "" {
-		return ""
+		return
+	}
+
+	for _, publicKey := range a.GetPublicKeys() {
+		certAuthrs = append(certAuthrs, fmt.Sprintf(
+			"@cert-authority *.%s %s", a.HostDomain, publicKey))
 	}
-	return fmt.Sprintf("@cert-authority *.%s %s", a.HostDomain, a.PublicKey)
+
+	return
 }
 
-func (a *Authority) GetBastionCertAuthority() string {
+func (a *Authority) GetBastionCertAuthority() (certAuthrs []string) {
+	certAuthrs = []string{}
+
 	bastionDomain := a.GetBastionDomain()
 	if bastionDomain == "" {
-		return ""
+		return
 	}
 
-	return fmt.Sprintf("@cert-authority %s %s", bastionDomain, a.PublicKey)
+	for _, publicKey := range a.GetPublicKeys() {
+		certAuthrs = append(certAuthrs, fmt.Sprintf(
+			"@cert-authority %s %s", bastionDomain, publicKey))
+	}
+
+	return
+}
+
+func (a *Authority) GetCertificateProfile() *CertificateProfile {
+	if a.CertificateProfile == nil {
+		return NewCertificateProfile()
+	}
+	return a.CertificateProfile
 }
 
 func (a *Authority) UserHasAccess(usr *user.User) bool {
@@ -225,46 +321,150 @@ func (a *Authority) UserHasAccess(usr *user.User) bool {
 	return usr.RolesMatch(a.Roles)
 }
 
+func (a *Authority) hostValidateClient(pubKey string) (
+	clnt *http.Client, mismatch *bool, err error) {
+
+	mismatch = new(bool)
+
+	if a.HostValidation != HostValidationHttps {
+		clnt = client
+		return
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKey))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse host public key"),
+		}
+		return
+	}
+
+	cryptoKey, ok := key.(ssh.CryptoPublicKey)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("authority: Unsupported host public key"),
+		}
+		return
+	}
+
+	keyBytes, err := x509.MarshalPKIXPublicKey(cryptoKey.CryptoPublicKey())
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal host public key"),
+		}
+		return
+	}
+
+	clnt = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				MinVersion:         tls.VersionTLS12,
+				InsecureSkipVerify: true,
+				VerifyPeerCertificate: func(rawCerts [][]byte,
+					_ [][]*x509.Certificate) (err error) {
+
+					if len(rawCerts) == 0 {
+						*mismatch = true
+						err = &errortypes.AuthenticationError{
+							errors.New("authority: Missing host certificate"),
+						}
+						return
+					}
+
+					cert, err := x509.ParseCertificate(rawCerts[0])
+					if err != nil {
+						err = &errortypes.ParseError{
+							errors.Wrap(err,
+								"authority: Failed to parse host certificate"),
+						}
+						return
+					}
+
+					certKeyBytes, err := x509.MarshalPKIXPublicKey(
+						cert.PublicKey)
+					if err != nil || !bytes.Equal(keyBytes, certKeyBytes) {
+						*mismatch = true
+						err = &errortypes.AuthenticationError{
+							errors.New(
+								"authority: Host certificate does not match"),
+						}
+						return
+					}
+
+					return
+				},
+			},
+		},
+	}
+
+	return
+}
+
 func (a *Authority) HostnameValidate(hostname string, port int,
-	pubKey string) bool {
+	pubKey string) (errData *errortypes.ErrorData) {
 
 	domain := a.GetDomain(hostname)
 
-	ipsNet, err := net.LookupIP(domain)
+	ips, err := net.LookupIP(domain)
 	if err != nil {
 		err = &errortypes.RequestError{
 			errors.Wrap(err, "authority: Failed to lookup host"),
 		}
 
 		logrus.WithFields(logrus.Fields{
+			"host":  domain,
 			"error": err,
 		}).Error("authority: Failed to lookup host")
 
-		return false
-	}
-
-	ips := []net.IP{}
-	for _, ip := range ipsNet {
-		if ip.To4() != nil {
-			ips = append(ips, ip)
+		errData = &errortypes.ErrorData{
+			Error:   "host_lookup_failed",
+			Message: "Failed to lookup host",
 		}
+		return
 	}
 
 	if len(ips) == 0 {
 		logrus.WithFields(logrus.Fields{
 			"host": domain,
-		}).Error("authority: No IPv4 addresses found for host")
-		return false
+		}).Error("authority: No addresses found for host")
+
+		errData = &errortypes.ErrorData{
+			Error:   "host_address_missing",
+			Message: "No addresses found for host",
+		}
+		return
+	}
+
+	clnt, mismatch, err := a.hostValidateClient(pubKey)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"host":  domain,
+			"error": err,
+		}).Error("authority: Host validation failed")
+
+		errData = &errortypes.ErrorData{
+			Error:   "host_key_invalid",
+			Message: "Host public key is not supported for validation",
+		}
+		return
+	}
+
+	scheme := "http"
+	if a.HostValidation == HostValidationHttps {
+		scheme = "https"
 	}
 
-	valid := false
 	url := ""
 	if port == 0 {
 		port = 9748
 	}
 
 	for _, ip := range ips {
-		url = fmt.Sprintf("http://%s:%d/challenge", ip, port)
+		*mismatch = false
+
+		url = fmt.Sprintf("%s://%s/challenge", scheme,
+			net.JoinHostPort(ip.String(), strconv.Itoa(port)))
 		req, e := http.NewRequest(
 			"GET",
 			url,
@@ -274,14 +474,29 @@ func (a *Authority) HostnameValidate(hostname string, port int,
 			err = &errortypes.RequestError{
 				errors.Wrap(e, "authority: Validation request failed"),
 			}
+			errData = &errortypes.ErrorData{
+				Error:   "host_unreachable",
+				Message: "Failed to connect to host validation service",
+			}
 			continue
 		}
 
-		resp, e := client.Do(req)
+		resp, e := clnt.Do(req)
 		if e != nil {
 			err = &errortypes.RequestError{
 				errors.Wrap(e, "authority: Validation request failed"),
 			}
+			if *mismatch {
+				errData = &errortypes.ErrorData{
+					Error:   "host_certificate_mismatch",
+					Message: "Host TLS certificate does not match host key",
+				}
+				break
+			}
+			errData = &errortypes.ErrorData{
+				Error:   "host_unreachable",
+				Message: "Failed to connect to host validation service",
+			}
 			continue
 		}
 		defer resp.Body.Close()
@@ -291,6 +506,12 @@ func (a *Authority) HostnameValidate(hostname string, port int,
 				errors.Newf("authority: Validation request bad status %d",
 					resp.StatusCode),
 			}
+			errData = &errortypes.ErrorData{
+				Error: "host_bad_status",
+				Message: fmt.Sprintf(
+					"Host validation service returned status %d",
+					resp.StatusCode),
+			}
 			continue
 		}
 
@@ -300,6 +521,10 @@ func (a *Authority) HostnameValidate(hostname string, port int,
 			err = &errortypes.ParseError{
 				errors.Wrap(e, "authority: Failed to parse response"),
 			}
+			errData = &errortypes.ErrorData{
+				Error:   "host_response_invalid",
+				Message: "Host validation response is invalid",
+			}
 			break
 		}
 
@@ -308,6 +533,10 @@ func (a *Authority) HostnameValidate(hostname string, port int,
 			err = errortypes.ParseError{
 				errors.New("authority: Public key too long"),
 			}
+			errData = &errortypes.ErrorData{
+				Error:   "host_response_invalid",
+				Message: "Host validation response is invalid",
+			}
 			break
 		}
 
@@ -317,114 +546,58 @@ func (a *Authority) HostnameValidate(hostname string, port int,
 			err = errortypes.AuthenticationError{
 				errors.New("authority: Public key does not match"),
 			}
+			errData = &errortypes.ErrorData{
+				Error:   "host_key_mismatch",
+				Message: "Host public key does not match",
+			}
 			break
 		}
 
-		valid = true
 		err = nil
+		errData = nil
 		break
 	}
 
-	if err != nil || !valid {
+	if err != nil || errData != nil {
 		logrus.WithFields(logrus.Fields{
 			"host":  domain,
 			"url":   url,
 			"error": err,
 		}).Error("authority: Host validation failed")
-		return false
-	}
-
-	return true
-}
-
-func (a *Authority) createCertificateLocal(
-	usr *user.User, sshPubKey string) (
-	cert *ssh.Certificate, certMarshaled string, err error) {
-
-	privateKey, err := ParsePemKey(a.PrivateKey)
-	if err != nil {
-		return
-	}
-
-	pubKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(sshPubKey))
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to parse ssh public key"),
-		}
-		return
-	}
 
-	serialHash := fnv.New64a()
-	serialHash.Write([]byte(primitive.NewObjectID().Hex()))
-	serial := serialHash.Sum64()
-
-	expire := a.Expire
-	if expire == 0 {
-		expire = 600
-	}
-	validAfter := time.Now().Add(-3 * time.Minute).Unix()
-	validBefore := time.Now().Add(
-		time.Duration(expire) * time.Minute).Unix()
-
-	if len(usr.Roles) == 0 {
-		err = &errortypes.AuthenticationError{
-			errors.Wrap(err, "authority: User has no roles"),
+		if errData == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "host_validation_failed",
+				Message: "Host validation failed",
+			}
 		}
 		return
 	}
 
-	roles := usr.Roles
-	if a.JumpProxy() != "" {
-		hasBastion := false
-
-		for _, role := range roles {
-			if role == "bastion" {
-				hasBastion = true
-				break
-			}
-		}
-
-		if !hasBastion {
-			roles = append(usr.Roles, "bastion")
-		}
-	}
+	return
+}
 
-	cert = &ssh.Certificate{
-		Key:             pubKey,
-		Serial:          serial,
-		CertType:        ssh.UserCert,
-		KeyId:           usr.Id.Hex(),
-		ValidPrincipals: roles,
-		ValidAfter:      uint64(validAfter),
-		ValidBefore:     uint64(validBefore),
-		Permissions: ssh.Permissions{
-			Extensions: map[string]string{
-				"permit-X11-forwarding":   "",
-				"permit-agent-forwarding": "",
-				"permit-port-forwarding":  "",
-				"permit-pty":              "",
-				"permit-user-rc":          "",
-			},
-		},
-	}
+func (a *Authority) signCertificate(db *database.Database,
+	cert *ssh.Certificate, comment string) (signed *ssh.Certificate,
+	certMarshaled string, err error) {
 
-	signer, err := ssh.NewSignerFromKey(privateKey)
+	signer, err := a.GetSigner()
 	if err != nil {
 		return
 	}
 
-	err = cert.SignCert(rand.Reader, signer)
+	signed, err = signer.SignCertificate(db, cert)
 	if err != nil {
 		return
 	}
 
-	certMarshaled = string(MarshalCertificate(cert, comment))
+	certMarshaled = string(MarshalCertificate(signed, comment))
 
 	return
 }
 
-func (a *Authority) createCertificateHsm(db *database.Database,
-	usr *user.User, sshPubKey string) (cert *ssh.Certificate,
+func (a *Authority) CreateCertificate(db *database.Database, usr *user.User,
+	agnt *agent.Agent, sshPubKey string, ttl int) (cert *ssh.Certificate,
 	certMarshaled string, err error) {
 
 	pubKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(sshPubKey))
@@ -435,290 +608,58 @@ func (a *Authority) createCertificateHsm(db *database.Database,
 		return
 	}
 
-	expire := a.Expire
-	if expire == 0 {
-		expire = 600
-	}
+	expire := a.GetExpire(usr, ttl)
 	validAfter := time.Now().Add(-3 * time.Minute).Unix()
 	validBefore := time.Now().Add(
 		time.Duration(expire) * time.Minute).Unix()
 
 	if len(usr.Roles) == 0 {
 		err = &errortypes.AuthenticationError{
-			errors.Wrap(err, "authority: User has no roles"),
-		}
-		return
-	}
-
-	roles := usr.Roles
-	if a.JumpProxy() != "" {
-		hasBastion := false
-
-		for _, role := range roles {
-			if role == "bastion" {
-				hasBastion = true
-				break
-			}
-		}
-
-		if !hasBastion {
-			roles = append(usr.Roles, "bastion")
-		}
-	}
-
-	cert = &ssh.Certificate{
-		Key:             pubKey,
-		CertType:        ssh.UserCert,
-		KeyId:           usr.Id.Hex(),
-		ValidPrincipals: roles,
-		ValidAfter:      uint64(validAfter),
-		ValidBefore:     uint64(validBefore),
-		Permissions: ssh.Permissions{
-			Extensions: map[string]string{
-				"permit-X11-forwarding":   "",
-				"permit-agent-forwarding": "",
-				"permit-port-forwarding":  "",
-				"permit-pty":              "",
-				"permit-user-rc":          "",
-			},
-		},
-	}
-
-	certData, err := utils.MarshalSshCertificate(cert)
-	if err != nil {
-		return
-	}
-
-	data := SshRequest{
-		Serial:      a.HsmSerial,
-		Certificate: certData,
-	}
-
-	cipData, err := json.Marshal(data)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to marshal certificate"),
+			errors.New("authority: User has no roles"),
 		}
 		return
 	}
 
-	pad := 16 - len(cipData)%16
-	for i := 0; i < pad; i++ {
-		cipData = append(cipData, 0)
-	}
-
-	encKeyHash := sha256.New()
-	encKeyHash.Write([]byte(a.HsmSecret))
-	cipKey := encKeyHash.Sum(nil)
-
-	cipIv, err := utils.RandBytes(aes.BlockSize)
+	profile := a.GetCertificateProfile()
+	criticalOptions, err := profile.CriticalOptions(agnt)
 	if err != nil {
 		return
 	}
 
-	block, err := aes.NewCipher(cipKey)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to load cipher"),
-		}
-		return
-	}
-
-	mode := cipher.NewCBCEncrypter(block, cipIv)
-	mode.CryptBlocks(cipData, cipData)
-
-	hashFunc := hmac.New(sha512.New, []byte(a.HsmSecret))
-	hashFunc.Write(cipData)
-	rawSignature := hashFunc.Sum(nil)
-	sig := base64.StdEncoding.EncodeToString(rawSignature)
-
-	payloadId := primitive.NewObjectID().Hex()
-	payload := &HsmPayload{
-		Id:        payloadId,
-		Token:     a.HsmToken,
-		Iv:        cipIv,
-		Signature: sig,
-		Type:      "ssh_certificate",
-		Data:      cipData,
-	}
-
-	waiter := sync.WaitGroup{}
-	waiter.Add(1)
-
-	timeout := time.Duration(settings.System.HsmResponseTimeout) * time.Second
-	start := time.Now()
-	var eventErr error
-
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				logrus.WithFields(logrus.Fields{
-					"error": errors.New(fmt.Sprintf("%s", r)),
-				}).Error("authority: Parse hsm panic")
-
-				eventErr = &errortypes.UnknownError{
-					errors.New("authority: Parse hsm panic"),
-				}
-			}
-			waiter.Done()
-		}()
-
-		event.SubscribeType([]string{"pritunl_hsm_recv"}, 5*time.Second,
-			func() event.CustomEvent {
-				return &HsmEvent{}
-			},
-			func(msgInf event.CustomEvent, e error) bool {
-				if e != nil {
-					eventErr = e
-					return false
-				}
-
-				if msgInf == nil || msgInf.GetData() == nil {
-					if time.Since(start) < timeout {
-						return true
-					}
-
-					eventErr = &errortypes.UnknownError{
-						errors.New("authority: Timeout waiting for hsm"),
-					}
-					return false
-				}
-
-				msg := msgInf.(*HsmEvent)
-
-				if msg.Data.Id != payloadId ||
-					msg.Data.Type != "ssh_certificate" {
-
-					if time.Since(start) < timeout {
-						return true
-					}
-					eventErr = &errortypes.UnknownError{
-						errors.New("authority: Timeout waiting for hsm"),
-					}
-					return false
-				}
-
-				payloadData, e := UnmarshalPayload(
-					a.HsmToken, a.HsmSecret, msg.Data)
-				if e != nil {
-					eventErr = e
-					return false
-				}
-
-				respData := &SshResponse{}
-				e = json.Unmarshal(payloadData, respData)
-				if e != nil {
-					eventErr = &errortypes.ParseError{
-						errors.Wrap(e,
-							"authority: Failed to unmarshal payload data"),
-					}
-					return false
-				}
-
-				cert, e = utils.UnmarshalSshCertificate(
-					respData.Certificate)
-				if e != nil {
-					eventErr = &errortypes.ParseError{
-						errors.Wrap(e,
-							"authority: Failed to unmarshal payload data"),
-					}
-					return false
-				}
-
-				certMarshaled = string(MarshalCertificate(cert, comment))
-
-				return false
-			})
-	}()
-
-	err = event.Publish(db, "pritunl_hsm_send", payload)
-	if err != nil {
-		return
-	}
-
-	waiter.Wait()
-	if eventErr != nil {
-		cert = nil
-		certMarshaled = ""
-		logrus.WithFields(logrus.Fields{
-			"error": eventErr,
-		}).Error("authority: Error getting hsm certificate")
-		return
-	}
-
-	return
-}
-
-func (a *Authority) CreateCertificate(db *database.Database, usr *user.User,
-	sshPubKey string) (cert *ssh.Certificate, certMarshaled string,
-	err error) {
-
-	if a.Type == PritunlHsm {
-		cert, certMarshaled, err = a.createCertificateHsm(db, usr, sshPubKey)
-	} else {
-		cert, certMarshaled, err = a.createCertificateLocal(usr, sshPubKey)
-	}
-
-	return
-}
-
-func (a *Authority) createHostCertificate(
-	hostname, domain string, sshPubKey string) (
-	cert *ssh.Certificate, certMarshaled string, err error) {
-
-	privateKey, err := ParsePemKey(a.PrivateKey)
-	if err != nil {
-		return
-	}
-
-	pubKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(sshPubKey))
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to parse ssh public key"),
+	principals := a.GetPrincipals(usr)
+	if len(principals) == 0 {
+		err = &errortypes.AuthenticationError{
+			errors.New("authority: User has no principals"),
 		}
 		return
 	}
 
-	serialHash := fnv.New64a()
-	serialHash.Write([]byte(primitive.NewObjectID().Hex()))
-	serial := serialHash.Sum64()
-
-	expire := a.HostExpire
-	if expire == 0 {
-		expire = 600
-	}
-	validAfter := time.Now().Add(-3 * time.Minute).Unix()
-	validBefore := time.Now().Add(
-		time.Duration(expire) * time.Minute).Unix()
-
 	cert = &ssh.Certificate{
 		Key:             pubKey,
-		Serial:          serial,
-		CertType:        ssh.HostCert,
-		KeyId:           hostname,
-		ValidPrincipals: []string{domain},
-		ValidAfter:      uint64(validAfter),
-		ValidBefore:     uint64(validBefore),
-	}
-
-	signer, err := ssh.NewSignerFromKey(privateKey)
-	if err != nil {
-		return
+		Serial:          GenerateSerial(),
+		CertType:        ssh.UserCert,
+		KeyId:           usr.Id.Hex(),
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(validAfter),
+		ValidBefore:     uint64(validBefore),
+		Permissions: ssh.Permissions{
+			CriticalOptions: criticalOptions,
+			Extensions:      profile.Extensions(),
+		},
 	}
 
-	err = cert.SignCert(rand.Reader, signer)
+	cert, certMarshaled, err = a.signCertificate(db, cert, comment)
 	if err != nil {
+		cert = nil
 		return
 	}
 
-	certMarshaled = string(MarshalCertificate(cert, comment))
-
 	return
 }
 
-func (a *Authority) createHostCertificateHsm(db *database.Database,
-	hostname, domain string, sshPubKey string) (cert *ssh.Certificate,
-	certMarshaled string, err error) {
+func (a *Authority) createHostCertificate(db *database.Database,
+	hostname string, principals []string, sshPubKey string) (
+	cert *ssh.Certificate, certMarshaled string, err error) {
 
 	pubKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(sshPubKey))
 	if err != nil {
@@ -738,172 +679,17 @@ func (a *Authority) createHostCertificateHsm(db *database.Database,
 
 	cert = &ssh.Certificate{
 		Key:             pubKey,
+		Serial:          GenerateSerial(),
 		CertType:        ssh.HostCert,
 		KeyId:           hostname,
-		ValidPrincipals: []string{domain},
+		ValidPrincipals: principals,
 		ValidAfter:      uint64(validAfter),
 		ValidBefore:     uint64(validBefore),
 	}
 
-	certData, err := utils.MarshalSshCertificate(cert)
-	if err != nil {
-		return
-	}
-
-	data := SshRequest{
-		Serial:      a.HsmSerial,
-		Certificate: certData,
-	}
-
-	cipData, err := json.Marshal(data)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to marshal certificate"),
-		}
-		return
-	}
-
-	pad := 16 - len(cipData)%16
-	for i := 0; i < pad; i++ {
-		cipData = append(cipData, 0)
-	}
-
-	encKeyHash := sha256.New()
-	encKeyHash.Write([]byte(a.HsmSecret))
-	cipKey := encKeyHash.Sum(nil)
-
-	cipIv, err := utils.RandBytes(aes.BlockSize)
-	if err != nil {
-		return
-	}
-
-	block, err := aes.NewCipher(cipKey)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "authority: Failed to load cipher"),
-		}
-		return
-	}
-
-	mode := cipher.NewCBCEncrypter(block, cipIv)
-	mode.CryptBlocks(cipData, cipData)
-
-	hashFunc := hmac.New(sha512.New, []byte(a.HsmSecret))
-	hashFunc.Write(cipData)
-	rawSignature := hashFunc.Sum(nil)
-	sig := base64.StdEncoding.EncodeToString(rawSignature)
-
-	payloadId := primitive.NewObjectID().Hex()
-	payload := &HsmPayload{
-		Id:        payloadId,
-		Token:     a.HsmToken,
-		Iv:        cipIv,
-		Signature: sig,
-		Type:      "ssh_certificate",
-		Data:      cipData,
-	}
-
-	waiter := sync.WaitGroup{}
-	waiter.Add(1)
-
-	timeout := time.Duration(settings.System.HsmResponseTimeout) * time.Second
-	start := time.Now()
-	var eventErr error
-
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				logrus.WithFields(logrus.Fields{
-					"error": errors.New(fmt.Sprintf("%s", r)),
-				}).Error("authority: Parse hsm panic")
-
-				eventErr = &errortypes.UnknownError{
-					errors.New("authority: Parse hsm panic"),
-				}
-			}
-			waiter.Done()
-		}()
-
-		event.SubscribeType([]string{"pritunl_hsm_recv"}, 5*time.Second,
-			func() event.CustomEvent {
-				return &HsmEvent{}
-			},
-			func(msgInf event.CustomEvent, e error) bool {
-				if e != nil {
-					eventErr = e
-					return false
-				}
-
-				if msgInf == nil || msgInf.GetData() == nil {
-					if time.Since(start) < timeout {
-						return true
-					}
-
-					eventErr = &errortypes.UnknownError{
-						errors.New("authority: Timeout waiting for hsm"),
-					}
-					return false
-				}
-
-				msg := msgInf.(*HsmEvent)
-
-				if msg.Data.Id != payloadId ||
-					msg.Data.Type != "ssh_certificate" {
-
-					if time.Since(start) < timeout {
-						return true
-					}
-					eventErr = &errortypes.UnknownError{
-						errors.New("authority: Timeout waiting for hsm"),
-					}
-					return false
-				}
-
-				payloadData, e := UnmarshalPayload(
-					a.HsmToken, a.HsmSecret, msg.Data)
-				if e != nil {
-					eventErr = e
-					return false
-				}
-
-				respData := &SshResponse{}
-				e = json.Unmarshal(payloadData, respData)
-				if e != nil {
-					eventErr = &errortypes.ParseError{
-						errors.Wrap(e,
-							"authority: Failed to unmarshal payload data"),
-					}
-					return false
-				}
-
-				cert, e = utils.UnmarshalSshCertificate(
-					respData.Certificate)
-				if e != nil {
-					eventErr = &errortypes.ParseError{
-						errors.Wrap(e,
-							"authority: Failed to unmarshal payload data"),
-					}
-					return false
-				}
-
-				certMarshaled = string(MarshalCertificate(cert, comment))
-
-				return false
-			})
-	}()
-
-	err = event.Publish(db, "pritunl_hsm_send", payload)
+	cert, certMarshaled, err = a.signCertificate(db, cert, comment)
 	if err != nil {
-		return
-	}
-
-	waiter.Wait()
-	if eventErr != nil {
 		cert = nil
-		certMarshaled = ""
-		logrus.WithFields(logrus.Fields{
-			"error": eventErr,
-		}).Error("authority: Error getting hsm certificate")
 		return
 	}
 
@@ -911,16 +697,11 @@ func (a *Authority) createHostCertificateHsm(db *database.Database,
 }
 
 func (a *Authority) CreateHostCertificate(db *database.Database,
-	hostname string, sshPubKey string) (
+	hostname string, aliases []string, sshPubKey string) (
 	cert *ssh.Certificate, certMarshaled string, err error) {
 
-	if a.Type == PritunlHsm {
-		cert, certMarshaled, err = a.createHostCertificateHsm(
-			db, hostname, a.GetDomain(hostname), sshPubKey)
-	} else {
-		cert, certMarshaled, err = a.createHostCertificate(
-			hostname, a.GetDomain(hostname), sshPubKey)
-	}
+	cert, certMarshaled, err = a.createHostCertificate(db, hostname,
+		a.GetHostPrincipals(hostname, aliases), sshPubKey)
 
 	return
 }
@@ -929,19 +710,21 @@ func (a *Authority) CreateBastionHostCertificate(db *database.Database,
 	hostname string, sshPubKey string) (
 	cert *ssh.Certificate, certMarshaled string, err error) {
 
-	if a.Type == PritunlHsm {
-		cert, certMarshaled, err = a.createHostCertificateHsm(
-			db, hostname, hostname, sshPubKey)
-	} else {
-		cert, certMarshaled, err = a.createHostCertificate(
-			hostname, hostname, sshPubKey)
-	}
+	cert, certMarshaled, err = a.createHostCertificate(
+		db, hostname, []string{hostname}, sshPubKey)
 
 	return
 }
 
-func (a *Authority) createRootCertificateLocal() (err error) {
-	privateKey, err := ParsePemKey(a.PrivateKey)
+func (a *Authority) CreateRootCertificate(db *database.Database) (
+	err error) {
+
+	signer, err := a.GetSigner()
+	if err != nil {
+		return
+	}
+
+	privateKey, err := signer.PrivateKey()
 	if err != nil {
 		return
 	}
@@ -951,10 +734,8 @@ func (a *Authority) createRootCertificateLocal() (err error) {
 		return
 	}
 
-	serialHash := fnv.New64a()
-	serialHash.Write([]byte(primitive.NewObjectID().Hex()))
 	serial := &big.Int{}
-	serial.SetUint64(serialHash.Sum64())
+	serial.SetUint64(GenerateSerial())
 
 	notBefore := time.Now().Add(-90 * time.Second)
 	notAfter := time.Now().Add(87600 * time.Hour)
@@ -1000,25 +781,15 @@ func (a *Authority) createRootCertificateLocal() (err error) {
 	return
 }
 
-func (a *Authority) CreateRootCertificate(db *database.Database) (
-	err error) {
+func (a *Authority) CreateClientCertificate(db *database.Database) (
+	clientCert *tls.Certificate, err error) {
 
-	if a.Type == PritunlHsm {
-		err = &errortypes.UnknownError{
-			errors.Wrap(err,
-				"authority: Root certificate not available on HSM"),
-		}
-	} else {
-		err = a.createRootCertificateLocal()
+	signer, err := a.GetSigner()
+	if err != nil {
+		return
 	}
 
-	return
-}
-
-func (a *Authority) createClientCertificateLocal() (
-	clientCert *tls.Certificate, err error) {
-
-	privateKey, err := ParsePemKey(a.PrivateKey)
+	privateKey, err := signer.PrivateKey()
 	if err != nil {
 		return
 	}
@@ -1047,10 +818,8 @@ func (a *Authority) createClientCertificateLocal() (
 		return
 	}
 
-	serialHash := fnv.New64a()
-	serialHash.Write([]byte(primitive.NewObjectID().Hex()))
 	serial := &big.Int{}
-	serial.SetUint64(serialHash.Sum64())
+	serial.SetUint64(GenerateSerial())
 
 	notBefore := time.Now().Add(-30 * time.Second)
 	notAfter := time.Now().Add(30 * time.Second)
@@ -1115,21 +884,6 @@ func (a *Authority) createClientCertificateLocal() (
 	return
 }
 
-func (a *Authority) CreateClientCertificate(db *database.Database) (
-	cert *tls.Certificate, err error) {
-
-	if a.Type == PritunlHsm {
-		err = &errortypes.UnknownError{
-			errors.Wrap(err,
-				"authority: Client certificate not available on HSM"),
-		}
-	} else {
-		cert, err = a.createClientCertificateLocal()
-	}
-
-	return
-}
-
 func (a *Authority) TokenNew() (err error) {
 	if a.HostTokens == nil {
 		a.HostTokens = []string{}
@@ -1196,6 +950,17 @@ func (a *Authority) HandleHsmStatus(db *database.Database,
 	if a.PublicKey != respData.SshPublicKey {
 		sendEvent = true
 		fields.Add("public_key")
+
+		if a.PublicKey != "" && respData.SshPublicKey != "" {
+			keyAlg := ""
+			if a.Info != nil {
+				keyAlg = a.Info.KeyAlg
+			}
+
+			a.addRotatedKey(a.PublicKey, keyAlg)
+			fields.Add("rotated_keys")
+		}
+
 		a.PublicKey = respData.SshPublicKey
 	}
 
@@ -1292,6 +1057,18 @@ func (a *Authority) Export(passphrase string) (encKey string, err error) {
 		return
 	}
 
+	if block.Type == "PRIVATE KEY" {
+		encBlock, e := encryptPkcs8(block.Bytes, passphrase)
+		if e != nil {
+			err = e
+			return
+		}
+
+		encKey = string(pem.EncodeToMemory(encBlock))
+
+		return
+	}
+
 	encBlock, err := x509.EncryptPEMBlock(
 		rand.Reader,
 		block.Type,
@@ -1313,6 +1090,83 @@ func (a *Authority) Export(passphrase string) (encKey string, err error) {
 	return
 }
 
+func (a *Authority) Import(passphrase, encKey string) (err error) {
+	block, _ := pem.Decode([]byte(encKey))
+	if block == nil {
+		err = &errortypes.ParseError{
+			errors.New("authority: Failed to decode private key"),
+		}
+		return
+	}
+
+	var privKeyBytes []byte
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		keyBytes, e := decryptPkcs8(block.Bytes, passphrase)
+		if e != nil {
+			err = e
+			return
+		}
+
+		privKeyBytes = pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: keyBytes,
+		})
+	} else {
+		keyBytes, e := x509.DecryptPEMBlock(block, []byte(passphrase))
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "authority: Failed to decrypt private key"),
+			}
+			return
+		}
+
+		privKeyBytes = pem.EncodeToMemory(&pem.Block{
+			Type:  block.Type,
+			Bytes: keyBytes,
+		})
+	}
+
+	privateKey, err := ParsePemKey(string(privKeyBytes))
+	if err != nil {
+		return
+	}
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse private key"),
+		}
+		return
+	}
+
+	publicKey := strings.TrimSpace(
+		string(MarshalPublicKey(signer.PublicKey())))
+
+	if a.PublicKey != "" {
+		curPubKey, _, _, _, e := ssh.ParseAuthorizedKey([]byte(a.PublicKey))
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "authority: Failed to parse public key"),
+			}
+			return
+		}
+
+		if !bytes.Equal(curPubKey.Marshal(), signer.PublicKey().Marshal()) {
+			err = &errortypes.ParseError{
+				errors.New("authority: Private key does not match " +
+					"public key"),
+			}
+			return
+		}
+	}
+
+	a.PrivateKey = strings.TrimSpace(string(privKeyBytes))
+	a.PublicKey = publicKey
+
+	return
+}
+
 func (a *Authority) JumpProxy() string {
 	if a.ProxyHosting {
 		return fmt.Sprintf(
@@ -1377,7 +1231,7 @@ func (a *Authority) SetPublicKeyPem() (err error) {
 		a.PublicKeyPem = strings.TrimSpace(string(pem.EncodeToMemory(block)))
 
 		break
-	case *ecdsa.PublicKey:
+	case *ecdsa.PublicKey, ed25519.PublicKey:
 		keyBytes, e := x509.MarshalPKIXPublicKey(pubKey)
 		if e != nil {
 			err = &errortypes.ParseError{
@@ -1418,11 +1272,53 @@ func (a *Authority) Validate(db *database.Database) (
 		a.HostSubnets = []string{}
 	}
 
+	if a.CertificateProfile == nil {
+		a.CertificateProfile = NewCertificateProfile()
+	}
+
+	errData = a.CertificateProfile.Validate()
+	if errData != nil {
+		return
+	}
+
+	errData = a.validatePrincipals()
+	if errData != nil {
+		return
+	}
+
+	errData = a.validateRoleExpires()
+	if errData != nil {
+		return
+	}
+
+	errData = a.validateApproval()
+	if errData != nil {
+		return
+	}
+
+	switch a.HostValidation {
+	case HostValidationHttp:
+		break
+	case HostValidationHttps:
+		break
+	case "":
+		a.HostValidation = HostValidationHttp
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "invalid_host_validation",
+			Message: "Invalid host validation mode",
+		}
+		return
+	}
+
 	switch a.Algorithm {
 	case RSA4096:
 		break
 	case ECP384:
 		break
+	case ED25519:
+		break
 	case "":
 		a.Algorithm = RSA4096
 		break
@@ -1439,6 +1335,9 @@ func (a *Authority) Validate(db *database.Database) (
 		a.HsmToken = ""
 		a.HsmSecret = ""
 		a.HsmSerial = ""
+		a.Pkcs11Module = ""
+		a.Pkcs11Token = ""
+		a.Pkcs11Key = ""
 
 		if a.PrivateKey == "" {
 			err = a.GeneratePrivateKey()
@@ -1450,6 +1349,9 @@ func (a *Authority) Validate(db *database.Database) (
 		break
 	case PritunlHsm:
 		a.PrivateKey = ""
+		a.Pkcs11Module = ""
+		a.Pkcs11Token = ""
+		a.Pkcs11Key = ""
 
 		if a.HsmSerial == "" {
 			errData = &errortypes.ErrorData{
@@ -1466,6 +1368,53 @@ func (a *Authority) Validate(db *database.Database) (
 			}
 		}
 
+		break
+	case Pkcs11:
+		a.PrivateKey = ""
+		a.HsmToken = ""
+		a.HsmSecret = ""
+		a.HsmSerial = ""
+
+		if a.Algorithm == ED25519 {
+			errData = &errortypes.ErrorData{
+				Error:   "invalid_pkcs11_algorithm",
+				Message: "PKCS#11 authority must use RSA 4096 or EC P384",
+			}
+			return
+		}
+
+		if a.Pkcs11Module == "" || a.Pkcs11Token == "" ||
+			a.Pkcs11Key == "" {
+
+			errData = &errortypes.ErrorData{
+				Error:   "missing_pkcs11",
+				Message: "Missing authority PKCS#11 module, token or key",
+			}
+			return
+		}
+
+		moduleValid := false
+		for _, module := range settings.System.Pkcs11Modules {
+			if module == a.Pkcs11Module {
+				moduleValid = true
+				break
+			}
+		}
+
+		if !moduleValid {
+			errData = &errortypes.ErrorData{
+				Error: "invalid_pkcs11_module",
+				Message: "PKCS#11 module must be allowed in " +
+					"system pkcs11_modules setting",
+			}
+			return
+		}
+
+		err = a.initPkcs11Key()
+		if err != nil {
+			return
+		}
+
 		break
 	default:
 		errData = &errortypes.ErrorData{
@@ -1495,6 +1444,16 @@ func (a *Authority) Validate(db *database.Database) (
 		a.Expire = 1440
 	}
 
+	if a.KeyOverlap < 1 {
+		a.KeyOverlap = DefaultKeyOverlap
+	} else if a.KeyOverlap > 8760 {
+		a.KeyOverlap = 8760
+	}
+
+	if a.RotatedKeys == nil {
+		a.RotatedKeys = []*RotatedKey{}
+	}
+
 	if a.HostExpire < 1 {
 		a.HostExpire = 600
 	} else if a.HostExpire > 1440 {
@@ -1595,6 +1554,12 @@ func (a *Authority) Json() {
 		}
 	}
 
+	if a.BastionStatus == BastionRunning &&
+		time.Since(a.BastionTimestamp) > 90*time.Second {
+
+		a.BastionStatus = BastionStopped
+	}
+
 	a.ProxyJump = a.JumpProxy()
 }
 
@@ -1622,6 +1587,25 @@ func (a *Authority) CommitFields(db *database.Database, fields set.Set) (
 	return
 }
 
+func (a *Authority) Upsert(db *database.Database) (err error) {
+	coll := db.Authorities()
+
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id": a.Id,
+	}, &bson.M{
+		"$set": a,
+	}, opts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func (a *Authority) Insert(db *database.Database) (err error) {
 	coll := db.Authorities()
 
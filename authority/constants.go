@@ -3,9 +3,26 @@ package authority
 const (
 	Local        = "local"
 	PritunlHsm   = "pritunl_hsm"
+	Pkcs11       = "pkcs11"
 	Connected    = "connected"
 	Disconnected = "disconnected"
 
+	BastionRunning    = "running"
+	BastionRestarting = "restarting"
+	BastionFailed     = "failed"
+	BastionStopped    = "stopped"
+
 	RSA4096 = "rsa4096"
 	ECP384  = "ecp384"
+	ED25519 = "ed25519"
+
+	HostValidationHttp  = "http"
+	HostValidationHttps = "https"
+
+	MaxHostAliases = 32
+
+	pkcs11SessionsMax = 8
+
+	DefaultKeyOverlap     = 720
+	DefaultApprovalExpire = 15
 )
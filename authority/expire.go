@@ -0,0 +1,84 @@
+package authority
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+type RoleExpire struct {
+	Role   string `bson:"role" json:"role"`
+	Expire int    `bson:"expire" json:"expire"`
+}
+
+func (a *Authority) GetExpire(usr *user.User, ttl int) (expire int) {
+	expire = a.Expire
+	if expire == 0 {
+		expire = 600
+	}
+
+	roleExpire := 0
+	for _, rolExpire := range a.RoleExpires {
+		for _, role := range usr.Roles {
+			if role != rolExpire.Role {
+				continue
+			}
+
+			if roleExpire == 0 || rolExpire.Expire < roleExpire {
+				roleExpire = rolExpire.Expire
+			}
+			break
+		}
+	}
+
+	if roleExpire != 0 {
+		expire = roleExpire
+	}
+
+	if usr.Machine != nil && usr.Machine.MaxTtl > 0 &&
+		usr.Machine.MaxTtl < expire {
+
+		expire = usr.Machine.MaxTtl
+	}
+
+	if ttl > 0 && ttl < expire {
+		expire = ttl
+	}
+
+	return
+}
+
+func (a *Authority) validateRoleExpires() (errData *errortypes.ErrorData) {
+	roleExpires := []*RoleExpire{}
+
+	for _, roleExpire := range a.RoleExpires {
+		if roleExpire == nil {
+			continue
+		}
+
+		roleExpire.Role = strings.TrimSpace(roleExpire.Role)
+		if roleExpire.Role == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "role_expire_invalid",
+				Message: "Role certificate lifetime is missing role",
+			}
+			return
+		}
+
+		if roleExpire.Expire < 1 || roleExpire.Expire > 1440 {
+			errData = &errortypes.ErrorData{
+				Error: "role_expire_invalid",
+				Message: "Role certificate lifetime must be " +
+					"between 1 and 1440 minutes",
+			}
+			return
+		}
+
+		roleExpires = append(roleExpires, roleExpire)
+	}
+
+	a.RoleExpires = roleExpires
+
+	return
+}
@@ -0,0 +1,161 @@
+package authority
+
+import (
+	"net"
+	"path"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func matchPatterns(patterns []string, host string) bool {
+	match := false
+
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		if negate {
+			pattern = pattern[1:]
+		}
+
+		ok, _ := path.Match(strings.ToLower(pattern), host)
+		if !ok {
+			continue
+		}
+
+		if negate {
+			return false
+		}
+		match = true
+	}
+
+	return match
+}
+
+func containsIp(ips []net.IP, ip net.IP) bool {
+	for _, x := range ips {
+		if x.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Authority) GetHostPatterns() (patterns []string) {
+	patterns = []string{}
+
+	if a.HostDomain != "" {
+		patterns = append(patterns, "*."+a.HostDomain)
+	}
+	for _, match := range a.HostMatches {
+		patterns = append(patterns, strings.Fields(match)...)
+	}
+
+	return
+}
+
+func (a *Authority) GetHostSubnets() (subnets []*net.IPNet) {
+	subnets = []*net.IPNet{}
+
+	for _, hostSubnet := range a.HostSubnets {
+		_, subnet, err := net.ParseCIDR(hostSubnet)
+		if err != nil {
+			continue
+		}
+		subnets = append(subnets, subnet)
+	}
+
+	return
+}
+
+func (a *Authority) MatchHostPattern(host string) bool {
+	return matchPatterns(a.GetHostPatterns(), host)
+}
+
+func (a *Authority) MatchHostSubnet(ip net.IP) bool {
+	for _, subnet := range a.GetHostSubnets() {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Authority) validateHostAlias(hostname, alias string,
+	hostIps []net.IP) bool {
+
+	if ip := net.ParseIP(alias); ip != nil {
+		return a.MatchHostSubnet(ip) && containsIp(hostIps, ip)
+	}
+
+	if alias == hostname {
+		return true
+	}
+
+	if !a.MatchHostPattern(alias) {
+		return false
+	}
+
+	ips, err := net.LookupIP(alias)
+	if err != nil {
+		return false
+	}
+
+	for _, ip := range ips {
+		if containsIp(hostIps, ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *Authority) GetHostPrincipals(hostname string,
+	aliases []string) (principals []string) {
+
+	domain := a.GetDomain(hostname)
+	principals = []string{domain}
+
+	if len(aliases) == 0 {
+		return
+	}
+
+	hostIps, err := net.LookupIP(domain)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": a.Id.Hex(),
+			"host":         domain,
+			"error":        err,
+		}).Warn("authority: Failed to lookup host for aliases")
+		return
+	}
+
+	added := map[string]bool{
+		domain: true,
+	}
+
+	for i, alias := range aliases {
+		if i >= MaxHostAliases {
+			break
+		}
+
+		alias = strings.ToLower(strings.TrimSuffix(
+			strings.TrimSpace(alias), "."))
+		if alias == "" || added[alias] {
+			continue
+		}
+
+		if !a.validateHostAlias(hostname, alias, hostIps) {
+			logrus.WithFields(logrus.Fields{
+				"authority_id": a.Id.Hex(),
+				"host":         domain,
+				"alias":        alias,
+			}).Warn("authority: Host alias failed verification")
+			continue
+		}
+
+		added[alias] = true
+		principals = append(principals, alias)
+	}
+
+	return
+}
@@ -9,11 +9,20 @@ import (
 	"crypto/sha512"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/database"
 	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/settings"
+	"github.com/pritunl/pritunl-zero/utils"
+	"golang.org/x/crypto/ssh"
 )
 
 type SshRequest struct {
@@ -128,3 +137,176 @@ func UnmarshalPayload(token, secret string, payload *HsmPayload) (
 
 	return
 }
+
+func (a *Authority) signCertificateHsm(db *database.Database,
+	cert *ssh.Certificate) (signed *ssh.Certificate, err error) {
+
+	certData, err := utils.MarshalSshCertificate(cert)
+	if err != nil {
+		return
+	}
+
+	data := SshRequest{
+		Serial:      a.HsmSerial,
+		Certificate: certData,
+	}
+
+	cipData, err := json.Marshal(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal certificate"),
+		}
+		return
+	}
+
+	pad := 16 - len(cipData)%16
+	for i := 0; i < pad; i++ {
+		cipData = append(cipData, 0)
+	}
+
+	encKeyHash := sha256.New()
+	encKeyHash.Write([]byte(a.HsmSecret))
+	cipKey := encKeyHash.Sum(nil)
+
+	cipIv, err := utils.RandBytes(aes.BlockSize)
+	if err != nil {
+		return
+	}
+
+	block, err := aes.NewCipher(cipKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to load cipher"),
+		}
+		return
+	}
+
+	mode := cipher.NewCBCEncrypter(block, cipIv)
+	mode.CryptBlocks(cipData, cipData)
+
+	hashFunc := hmac.New(sha512.New, []byte(a.HsmSecret))
+	hashFunc.Write(cipData)
+	rawSignature := hashFunc.Sum(nil)
+	sig := base64.StdEncoding.EncodeToString(rawSignature)
+
+	payloadId := primitive.NewObjectID().Hex()
+	payload := &HsmPayload{
+		Id:        payloadId,
+		Token:     a.HsmToken,
+		Iv:        cipIv,
+		Signature: sig,
+		Type:      "ssh_certificate",
+		Data:      cipData,
+	}
+
+	waiter := sync.WaitGroup{}
+	waiter.Add(1)
+
+	timeout := time.Duration(settings.System.HsmResponseTimeout) * time.Second
+	start := time.Now()
+	var eventErr error
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": errors.New(fmt.Sprintf("%s", r)),
+				}).Error("authority: Parse hsm panic")
+
+				eventErr = &errortypes.UnknownError{
+					errors.New("authority: Parse hsm panic"),
+				}
+			}
+			waiter.Done()
+		}()
+
+		event.SubscribeType([]string{"pritunl_hsm_recv"}, 5*time.Second,
+			func() event.CustomEvent {
+				return &HsmEvent{}
+			},
+			func(msgInf event.CustomEvent, e error) bool {
+				if e != nil {
+					eventErr = e
+					return false
+				}
+
+				if msgInf == nil || msgInf.GetData() == nil {
+					if time.Since(start) < timeout {
+						return true
+					}
+
+					eventErr = &errortypes.UnknownError{
+						errors.New("authority: Timeout waiting for hsm"),
+					}
+					return false
+				}
+
+				msg := msgInf.(*HsmEvent)
+
+				if msg.Data.Id != payloadId ||
+					msg.Data.Type != "ssh_certificate" {
+
+					if time.Since(start) < timeout {
+						return true
+					}
+					eventErr = &errortypes.UnknownError{
+						errors.New("authority: Timeout waiting for hsm"),
+					}
+					return false
+				}
+
+				payloadData, e := UnmarshalPayload(
+					a.HsmToken, a.HsmSecret, msg.Data)
+				if e != nil {
+					eventErr = e
+					return false
+				}
+
+				respData := &SshResponse{}
+				e = json.Unmarshal(payloadData, respData)
+				if e != nil {
+					eventErr = &errortypes.ParseError{
+						errors.Wrap(e,
+							"authority: Failed to unmarshal payload data"),
+					}
+					return false
+				}
+
+				signed, e = utils.UnmarshalSshCertificate(
+					respData.Certificate)
+				if e != nil {
+					eventErr = &errortypes.ParseError{
+						errors.Wrap(e,
+							"authority: Failed to unmarshal payload data"),
+					}
+					return false
+				}
+
+				return false
+			})
+	}()
+
+	err = event.Publish(db, "pritunl_hsm_send", payload)
+	if err != nil {
+		return
+	}
+
+	waiter.Wait()
+	if eventErr != nil {
+		signed = nil
+		err = eventErr
+		logrus.WithFields(logrus.Fields{
+			"error": eventErr,
+		}).Error("authority: Error getting hsm certificate")
+		return
+	}
+
+	if signed == nil {
+		err = &errortypes.UnknownError{
+			errors.New("authority: Missing hsm certificate"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,580 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/miekg/pkcs11"
+	"github.com/pritunl/pritunl-zero/config"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	pkcs11Ctxs         = map[string]*pkcs11.Ctx{}
+	pkcs11CtxsLock     = sync.Mutex{}
+	pkcs11Sessions     = map[string][]*pkcs11Session{}
+	pkcs11SessionsLock = sync.Mutex{}
+	oidCurveP384       = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	rsaDigestInfo      = map[crypto.Hash][]byte{
+		crypto.SHA1: {
+			0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02,
+			0x1a, 0x05, 0x00, 0x04, 0x14,
+		},
+		crypto.SHA256: {
+			0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+			0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20,
+		},
+		crypto.SHA384: {
+			0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+			0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30,
+		},
+		crypto.SHA512: {
+			0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+			0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40,
+		},
+	}
+)
+
+func getPkcs11Ctx(module string) (ctx *pkcs11.Ctx, err error) {
+	pkcs11CtxsLock.Lock()
+	defer pkcs11CtxsLock.Unlock()
+
+	ctx = pkcs11Ctxs[module]
+	if ctx != nil {
+		return
+	}
+
+	ctx = pkcs11.New(module)
+	if ctx == nil {
+		err = &errortypes.ReadError{
+			errors.New("authority: Failed to load pkcs11 module"),
+		}
+		return
+	}
+
+	err = ctx.Initialize()
+	if err != nil {
+		if e, ok := err.(pkcs11.Error); !ok ||
+			e != pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED {
+
+			ctx.Destroy()
+			ctx = nil
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "authority: Failed to initialize pkcs11"),
+			}
+			return
+		}
+		err = nil
+	}
+
+	pkcs11Ctxs[module] = ctx
+
+	return
+}
+
+type pkcs11Session struct {
+	key    string
+	ctx    *pkcs11.Ctx
+	handle pkcs11.SessionHandle
+}
+
+func (s *pkcs11Session) Close() {
+	s.ctx.CloseSession(s.handle)
+}
+
+func (s *pkcs11Session) Release() {
+	pkcs11SessionsLock.Lock()
+	if len(pkcs11Sessions[s.key]) < pkcs11SessionsMax {
+		pkcs11Sessions[s.key] = append(pkcs11Sessions[s.key], s)
+		pkcs11SessionsLock.Unlock()
+		return
+	}
+	pkcs11SessionsLock.Unlock()
+
+	s.Close()
+}
+
+func (s *pkcs11Session) loggedIn() bool {
+	info, err := s.ctx.GetSessionInfo(s.handle)
+	if err != nil {
+		return false
+	}
+
+	return info.State == pkcs11.CKS_RW_USER_FUNCTIONS
+}
+
+func getPkcs11Session(key string) (sess *pkcs11Session) {
+	pkcs11SessionsLock.Lock()
+	defer pkcs11SessionsLock.Unlock()
+
+	pool := pkcs11Sessions[key]
+	for len(pool) > 0 {
+		sess = pool[len(pool)-1]
+		pool = pool[:len(pool)-1]
+		pkcs11Sessions[key] = pool
+
+		if sess.loggedIn() {
+			return
+		}
+
+		sess.Close()
+		sess = nil
+	}
+
+	return
+}
+
+func (s *pkcs11Session) findObject(class uint, label string) (
+	obj pkcs11.ObjectHandle, err error) {
+
+	err = s.ctx.FindObjectsInit(s.handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to find pkcs11 object"),
+		}
+		return
+	}
+
+	objs, _, err := s.ctx.FindObjects(s.handle, 1)
+	e := s.ctx.FindObjectsFinal(s.handle)
+	if err == nil {
+		err = e
+	}
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to find pkcs11 object"),
+		}
+		return
+	}
+
+	if len(objs) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("authority: Failed to find pkcs11 key"),
+		}
+		return
+	}
+
+	obj = objs[0]
+
+	return
+}
+
+func (s *pkcs11Session) publicKey(label string) (
+	pubKey crypto.PublicKey, err error) {
+
+	obj, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return
+	}
+
+	attrs, err := s.ctx.GetAttributeValue(s.handle, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil || len(attrs) != 1 {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to read pkcs11 key type"),
+		}
+		return
+	}
+
+	keyType := uint64(0)
+	keyTypeVal := attrs[0].Value
+	switch len(keyTypeVal) {
+	case 4:
+		keyType = uint64(binary.NativeEndian.Uint32(keyTypeVal))
+		break
+	case 8:
+		keyType = binary.NativeEndian.Uint64(keyTypeVal)
+		break
+	default:
+		err = &errortypes.ReadError{
+			errors.New("authority: Invalid pkcs11 key type length"),
+		}
+		return
+	}
+
+	switch keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err = s.ctx.GetAttributeValue(
+			s.handle, obj, []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+				pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+			})
+		if err != nil || len(attrs) != 2 {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "authority: Failed to read pkcs11 rsa key"),
+			}
+			return
+		}
+
+		pubKey = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}
+		break
+	case pkcs11.CKK_EC:
+		attrs, err = s.ctx.GetAttributeValue(
+			s.handle, obj, []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+				pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+			})
+		if err != nil || len(attrs) != 2 {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "authority: Failed to read pkcs11 ec key"),
+			}
+			return
+		}
+
+		curveOid := asn1.ObjectIdentifier{}
+		_, err = asn1.Unmarshal(attrs[0].Value, &curveOid)
+		if err != nil || !curveOid.Equal(oidCurveP384) {
+			err = &errortypes.ParseError{
+				errors.New("authority: Unsupported pkcs11 ec curve"),
+			}
+			return
+		}
+
+		point := attrs[1].Value
+		rawPoint := []byte{}
+		rest, e := asn1.Unmarshal(point, &rawPoint)
+		if e == nil && len(rest) == 0 {
+			point = rawPoint
+		}
+
+		x, y := elliptic.Unmarshal(elliptic.P384(), point)
+		if x == nil {
+			err = &errortypes.ParseError{
+				errors.New("authority: Failed to parse pkcs11 ec point"),
+			}
+			return
+		}
+
+		pubKey = &ecdsa.PublicKey{
+			Curve: elliptic.P384(),
+			X:     x,
+			Y:     y,
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.New("authority: Unsupported pkcs11 key type"),
+		}
+		return
+	}
+
+	return
+}
+
+func (a *Authority) openPkcs11Session() (sess *pkcs11Session, err error) {
+	key := a.Pkcs11Module + ":" + a.Pkcs11Token
+
+	sess = getPkcs11Session(key)
+	if sess != nil {
+		return
+	}
+
+	pin := config.Config.Pkcs11Pins[a.Pkcs11Token]
+	if pin == "" {
+		err = &errortypes.AuthenticationError{
+			errors.New("authority: Missing pkcs11 pin in node config"),
+		}
+		return
+	}
+
+	ctx, err := getPkcs11Ctx(a.Pkcs11Module)
+	if err != nil {
+		return
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to get pkcs11 slots"),
+		}
+		return
+	}
+
+	slotId := uint(0)
+	found := false
+	for _, slot := range slots {
+		info, e := ctx.GetTokenInfo(slot)
+		if e != nil {
+			continue
+		}
+
+		if strings.TrimSpace(info.Label) == a.Pkcs11Token {
+			slotId = slot
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		err = &errortypes.NotFoundError{
+			errors.New("authority: Failed to find pkcs11 token"),
+		}
+		return
+	}
+
+	handle, err := ctx.OpenSession(
+		slotId, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to open pkcs11 session"),
+		}
+		return
+	}
+
+	sess = &pkcs11Session{
+		key:    key,
+		ctx:    ctx,
+		handle: handle,
+	}
+
+	if sess.loggedIn() {
+		return
+	}
+
+	err = ctx.Login(handle, pkcs11.CKU_USER, pin)
+	if err != nil {
+		if e, ok := err.(pkcs11.Error); !ok ||
+			e != pkcs11.CKR_USER_ALREADY_LOGGED_IN {
+
+			sess.Close()
+			sess = nil
+			err = &errortypes.AuthenticationError{
+				errors.Wrap(err, "authority: Failed to login to pkcs11"),
+			}
+			return
+		}
+		err = nil
+	}
+
+	return
+}
+
+func (a *Authority) initPkcs11Key() (err error) {
+	sess, err := a.openPkcs11Session()
+	if err != nil {
+		return
+	}
+	defer sess.Close()
+
+	pubKey, err := sess.publicKey(a.Pkcs11Key)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); !ok {
+			return
+		}
+		err = nil
+
+		var mechanism *pkcs11.Mechanism
+		publicTempl := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, a.Pkcs11Key),
+		}
+		privateTempl := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, a.Pkcs11Key),
+		}
+
+		if a.Algorithm == ECP384 {
+			curveParams, e := asn1.Marshal(oidCurveP384)
+			if e != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(e, "authority: Failed to marshal ec curve"),
+				}
+				return
+			}
+
+			mechanism = pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)
+			publicTempl = append(publicTempl, pkcs11.NewAttribute(
+				pkcs11.CKA_EC_PARAMS, curveParams))
+		} else {
+			mechanism = pkcs11.NewMechanism(
+				pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)
+			publicTempl = append(publicTempl,
+				pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 4096),
+				pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT,
+					[]byte{1, 0, 1}),
+			)
+		}
+
+		_, _, err = sess.ctx.GenerateKeyPair(sess.handle,
+			[]*pkcs11.Mechanism{mechanism}, publicTempl, privateTempl)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "authority: Failed to generate pkcs11 key"),
+			}
+			return
+		}
+
+		pubKey, err = sess.publicKey(a.Pkcs11Key)
+		if err != nil {
+			return
+		}
+	}
+
+	sshPubKey, err := ssh.NewPublicKey(pubKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse pkcs11 public key"),
+		}
+		return
+	}
+
+	keyAlg := "RSA 4096"
+	if _, ok := pubKey.(*ecdsa.PublicKey); ok {
+		keyAlg = "EC P384"
+	}
+
+	publicKey := strings.TrimSpace(string(MarshalPublicKey(sshPubKey)))
+
+	a.Info = &Info{
+		KeyAlg: keyAlg,
+	}
+	a.PrivateKey = ""
+
+	if a.PublicKey != publicKey {
+		a.PublicKey = publicKey
+		a.PublicKeyPem = ""
+		a.RootCertificate = ""
+
+		err = a.SetPublicKeyPem()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+type pkcs11Signer struct {
+	authr  *Authority
+	pubKey crypto.PublicKey
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.pubKey
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte,
+	opts crypto.SignerOpts) (sig []byte, err error) {
+
+	sess, err := s.authr.openPkcs11Session()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			sess.Close()
+		} else {
+			sess.Release()
+		}
+	}()
+
+	obj, err := sess.findObject(pkcs11.CKO_PRIVATE_KEY, s.authr.Pkcs11Key)
+	if err != nil {
+		return
+	}
+
+	var mechanism *pkcs11.Mechanism
+	data := digest
+
+	switch s.pubKey.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			err = &errortypes.ParseError{
+				errors.New("authority: Unsupported pkcs11 rsa padding"),
+			}
+			return
+		}
+
+		prefix, ok := rsaDigestInfo[opts.HashFunc()]
+		if !ok {
+			err = &errortypes.ParseError{
+				errors.New("authority: Unsupported pkcs11 hash"),
+			}
+			return
+		}
+
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+		data = append(append([]byte{}, prefix...), digest...)
+		break
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.New("authority: Unsupported pkcs11 key type"),
+		}
+		return
+	}
+
+	err = sess.ctx.SignInit(sess.handle,
+		[]*pkcs11.Mechanism{mechanism}, obj)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "authority: Failed to init pkcs11 sign"),
+		}
+		return
+	}
+
+	sig, err = sess.ctx.Sign(sess.handle, data)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "authority: Failed to pkcs11 sign"),
+		}
+		return
+	}
+
+	if _, ok := s.pubKey.(*ecdsa.PublicKey); ok {
+		half := len(sig) / 2
+		sig, err = asn1.Marshal(struct {
+			R *big.Int
+			S *big.Int
+		}{
+			R: new(big.Int).SetBytes(sig[:half]),
+			S: new(big.Int).SetBytes(sig[half:]),
+		})
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "authority: Failed to marshal ec signature"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (a *Authority) getPkcs11Signer() (signer crypto.Signer, err error) {
+	pubKey, err := ParseSshPubKey(a.PublicKey)
+	if err != nil {
+		return
+	}
+
+	signer = &pkcs11Signer{
+		authr:  a,
+		pubKey: pubKey,
+	}
+
+	return
+}
@@ -0,0 +1,111 @@
+//go:build softhsm
+// +build softhsm
+
+package authority
+
+import (
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// Requires an initialized SoftHSMv2 token, see README PKCS#11 Authorities
+func newTestPkcs11Authority(t *testing.T, algorithm string) (
+	authr *Authority) {
+
+	module := os.Getenv("SOFTHSM_MODULE")
+	token := os.Getenv("SOFTHSM_TOKEN")
+	pin := os.Getenv("SOFTHSM_PIN")
+	if module == "" || token == "" || pin == "" {
+		t.Skip("SOFTHSM_MODULE, SOFTHSM_TOKEN and SOFTHSM_PIN not set")
+	}
+
+	config.Config.Pkcs11Pins = map[string]string{
+		token: pin,
+	}
+
+	authr = &Authority{
+		Id:           primitive.NewObjectID(),
+		Type:         Pkcs11,
+		Algorithm:    algorithm,
+		Pkcs11Module: module,
+		Pkcs11Token:  token,
+		Pkcs11Key:    "pritunl-zero-test-" + primitive.NewObjectID().Hex(),
+	}
+
+	return
+}
+
+func TestPkcs11Sign(t *testing.T) {
+	for _, algorithm := range []string{RSA4096, ECP384} {
+		authr := newTestPkcs11Authority(t, algorithm)
+
+		err := authr.initPkcs11Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authr.PublicKey == "" || authr.PublicKeyPem == "" {
+			t.Fatalf("Missing pkcs11 public key %s", algorithm)
+		}
+
+		pubKey := authr.PublicKey
+		err = authr.initPkcs11Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authr.PublicKey != pubKey {
+			t.Errorf("Regenerated existing pkcs11 key %s", algorithm)
+		}
+
+		key, err := authr.getPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signer, err := ssh.NewSignerFromSigner(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sshPubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			data := []byte("pritunl-zero-test")
+
+			sig, err := signer.Sign(rand.Reader, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = sshPubKey.Verify(data, sig)
+			if err != nil {
+				t.Errorf("Invalid pkcs11 signature %s: %s", algorithm, err)
+			}
+		}
+
+		sessKey := authr.Pkcs11Module + ":" + authr.Pkcs11Token
+		if len(pkcs11Sessions[sessKey]) != 1 {
+			t.Errorf("Wrong pkcs11 session pool %s: %d",
+				algorithm, len(pkcs11Sessions[sessKey]))
+		}
+	}
+}
+
+func TestPkcs11MissingPin(t *testing.T) {
+	authr := newTestPkcs11Authority(t, ECP384)
+	authr.Pkcs11Token = "pritunl-zero-missing"
+
+	err := authr.initPkcs11Key()
+	if err == nil {
+		t.Error("Expected missing pkcs11 pin error")
+	}
+}
@@ -0,0 +1,238 @@
+package authority
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"hash"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	oidPbes2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPbkdf2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHmacSha1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHmacSha256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAes256Cbc  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const (
+	pkcs8SaltLen    = 16
+	pkcs8Iterations = 100000
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	Prf            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+func encryptPkcs8(data []byte, passphrase string) (
+	block *pem.Block, err error) {
+
+	salt := make([]byte, pkcs8SaltLen)
+	_, err = rand.Read(salt)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "authority: Failed to read random"),
+		}
+		return
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "authority: Failed to read random"),
+		}
+		return
+	}
+
+	key := pbkdf2.Key([]byte(passphrase), salt, pkcs8Iterations,
+		32, sha256.New)
+
+	blockCipher, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "authority: Failed to load cipher"),
+		}
+		return
+	}
+
+	padLen := aes.BlockSize - len(data)%aes.BlockSize
+	encData := make([]byte, len(data), len(data)+padLen)
+	copy(encData, data)
+	encData = append(encData, bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+
+	cipher.NewCBCEncrypter(blockCipher, iv).CryptBlocks(encData, encData)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pkcs8Iterations,
+		Prf: pkix.AlgorithmIdentifier{
+			Algorithm:  oidHmacSha256,
+			Parameters: asn1.NullRawValue,
+		},
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal kdf params"),
+		}
+		return
+	}
+
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal cipher params"),
+		}
+		return
+	}
+
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPbkdf2,
+			Parameters: asn1.RawValue{FullBytes: kdfParams},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAes256Cbc,
+			Parameters: asn1.RawValue{FullBytes: ivParams},
+		},
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal pbes2 params"),
+		}
+		return
+	}
+
+	encKey, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPbes2,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		EncryptedData: encData,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal private key"),
+		}
+		return
+	}
+
+	block = &pem.Block{
+		Type:  "ENCRYPTED PRIVATE KEY",
+		Bytes: encKey,
+	}
+
+	return
+}
+
+func decryptPkcs8(data []byte, passphrase string) (
+	keyBytes []byte, err error) {
+
+	encKey := encryptedPrivateKeyInfo{}
+	params := pbes2Params{}
+	kdfParams := pbkdf2Params{}
+	var iv []byte
+
+	_, err = asn1.Unmarshal(data, &encKey)
+	if err == nil {
+		_, err = asn1.Unmarshal(
+			encKey.Algorithm.Parameters.FullBytes, &params)
+	}
+	if err == nil {
+		_, err = asn1.Unmarshal(
+			params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams)
+	}
+	if err == nil {
+		_, err = asn1.Unmarshal(
+			params.EncryptionScheme.Parameters.FullBytes, &iv)
+	}
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse encrypted key"),
+		}
+		return
+	}
+
+	var hashFunc func() hash.Hash
+	switch {
+	case kdfParams.Prf.Algorithm == nil,
+		kdfParams.Prf.Algorithm.Equal(oidHmacSha1):
+
+		hashFunc = sha1.New
+		break
+	case kdfParams.Prf.Algorithm.Equal(oidHmacSha256):
+		hashFunc = sha256.New
+		break
+	}
+
+	if !encKey.Algorithm.Algorithm.Equal(oidPbes2) ||
+		!params.KeyDerivationFunc.Algorithm.Equal(oidPbkdf2) ||
+		!params.EncryptionScheme.Algorithm.Equal(oidAes256Cbc) ||
+		hashFunc == nil || len(iv) != aes.BlockSize ||
+		kdfParams.IterationCount < 1 {
+
+		err = &errortypes.ParseError{
+			errors.New("authority: Unsupported encrypted key algorithm"),
+		}
+		return
+	}
+
+	encData := encKey.EncryptedData
+	if len(encData) == 0 || len(encData)%aes.BlockSize != 0 {
+		err = &errortypes.ParseError{
+			errors.New("authority: Invalid encrypted key length"),
+		}
+		return
+	}
+
+	key := pbkdf2.Key([]byte(passphrase), kdfParams.Salt,
+		kdfParams.IterationCount, 32, hashFunc)
+
+	blockCipher, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "authority: Failed to load cipher"),
+		}
+		return
+	}
+
+	keyBytes = make([]byte, len(encData))
+	cipher.NewCBCDecrypter(blockCipher, iv).CryptBlocks(keyBytes, encData)
+
+	padLen := int(keyBytes[len(keyBytes)-1])
+	if padLen == 0 || padLen > aes.BlockSize ||
+		!bytes.Equal(keyBytes[len(keyBytes)-padLen:],
+			bytes.Repeat([]byte{byte(padLen)}, padLen)) {
+
+		keyBytes = nil
+		err = &errortypes.AuthenticationError{
+			errors.New("authority: Invalid passphrase"),
+		}
+		return
+	}
+	keyBytes = keyBytes[:len(keyBytes)-padLen]
+
+	return
+}
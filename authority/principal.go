@@ -0,0 +1,191 @@
+package authority
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+var (
+	principalVarRe = regexp.MustCompile(`{{\s*([a-z_]*)\s*}}`)
+	principalVars  = map[string]bool{
+		"username":    true,
+		"email_local": true,
+		"user_id":     true,
+		"role":        true,
+	}
+)
+
+type PrincipalMapping struct {
+	Role      string `bson:"role" json:"role"`
+	Principal string `bson:"principal" json:"principal"`
+}
+
+func formatPrincipal(principal string) string {
+	principal = strings.TrimSpace(principal)
+	principal = strings.Map(func(r rune) rune {
+		if r == ',' || r <= ' ' {
+			return -1
+		}
+		return r
+	}, principal)
+	return principal
+}
+
+func renderPrincipal(tmpl string, usr *user.User, role string) string {
+	emailLocal := usr.Username
+	if i := strings.LastIndex(emailLocal, "@"); i != -1 {
+		emailLocal = emailLocal[:i]
+	}
+
+	return formatPrincipal(principalVarRe.ReplaceAllStringFunc(tmpl,
+		func(match string) string {
+			name := principalVarRe.FindStringSubmatch(match)[1]
+
+			switch name {
+			case "username":
+				return usr.Username
+			case "email_local":
+				return emailLocal
+			case "user_id":
+				return usr.Id.Hex()
+			case "role":
+				return role
+			}
+
+			return ""
+		}))
+}
+
+func hasRoleVar(tmpl string) bool {
+	for _, match := range principalVarRe.FindAllStringSubmatch(tmpl, -1) {
+		if match[1] == "role" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validatePrincipal(tmpl string) bool {
+	for _, match := range principalVarRe.FindAllStringSubmatch(tmpl, -1) {
+		if !principalVars[match[1]] {
+			return false
+		}
+	}
+
+	tmpl = principalVarRe.ReplaceAllString(tmpl, "")
+	if strings.Contains(tmpl, "{{") || strings.Contains(tmpl, "}}") {
+		return false
+	}
+
+	return true
+}
+
+func (a *Authority) GetPrincipals(usr *user.User) (principals []string) {
+	principals = []string{}
+	principalsSet := map[string]bool{}
+
+	add := func(principal string) {
+		if principal == "" || principalsSet[principal] {
+			return
+		}
+		principalsSet[principal] = true
+		principals = append(principals, principal)
+	}
+
+	templates := a.PrincipalTemplates
+	if len(templates) == 0 && len(a.PrincipalMappings) == 0 {
+		templates = []string{"{{role}}"}
+	}
+
+	for _, tmpl := range templates {
+		if hasRoleVar(tmpl) {
+			for _, role := range usr.Roles {
+				add(renderPrincipal(tmpl, usr, role))
+			}
+		} else {
+			add(renderPrincipal(tmpl, usr, ""))
+		}
+	}
+
+	for _, mapping := range a.PrincipalMappings {
+		for _, role := range usr.Roles {
+			if role == mapping.Role {
+				add(renderPrincipal(mapping.Principal, usr, role))
+				break
+			}
+		}
+	}
+
+	jumpProxy := a.JumpProxy() != ""
+	if jumpProxy {
+		add("bastion")
+	}
+
+	if usr.Machine != nil {
+		machinePrincipals := []string{}
+		for _, principal := range principals {
+			if usr.Machine.HasPrincipal(principal) ||
+				(jumpProxy && principal == "bastion") {
+
+				machinePrincipals = append(machinePrincipals, principal)
+			}
+		}
+		principals = machinePrincipals
+	}
+
+	return
+}
+
+func (a *Authority) validatePrincipals() (errData *errortypes.ErrorData) {
+	templates := []string{}
+	for _, tmpl := range a.PrincipalTemplates {
+		tmpl = strings.TrimSpace(tmpl)
+		if tmpl == "" {
+			continue
+		}
+
+		if !validatePrincipal(tmpl) {
+			errData = &errortypes.ErrorData{
+				Error:   "principal_template_invalid",
+				Message: "Principal template is invalid",
+			}
+			return
+		}
+
+		templates = append(templates, tmpl)
+	}
+	a.PrincipalTemplates = templates
+
+	mappings := []*PrincipalMapping{}
+	for _, mapping := range a.PrincipalMappings {
+		if mapping == nil {
+			continue
+		}
+
+		mapping.Role = strings.TrimSpace(mapping.Role)
+		mapping.Principal = strings.TrimSpace(mapping.Principal)
+
+		if mapping.Role == "" && mapping.Principal == "" {
+			continue
+		}
+
+		if mapping.Role == "" || mapping.Principal == "" ||
+			!validatePrincipal(mapping.Principal) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "principal_mapping_invalid",
+				Message: "Principal mapping is invalid",
+			}
+			return
+		}
+
+		mappings = append(mappings, mapping)
+	}
+	a.PrincipalMappings = mappings
+
+	return
+}
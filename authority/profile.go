@@ -0,0 +1,135 @@
+package authority
+
+import (
+	"net"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+type CertificateProfile struct {
+	PermitX11Forwarding   bool   `bson:"permit_x11_forwarding" json:"permit_x11_forwarding"`
+	PermitAgentForwarding bool   `bson:"permit_agent_forwarding" json:"permit_agent_forwarding"`
+	PermitPortForwarding  bool   `bson:"permit_port_forwarding" json:"permit_port_forwarding"`
+	PermitPty             bool   `bson:"permit_pty" json:"permit_pty"`
+	PermitUserRc          bool   `bson:"permit_user_rc" json:"permit_user_rc"`
+	ForceCommand          string `bson:"force_command" json:"force_command"`
+	SourceAddress         bool   `bson:"source_address" json:"source_address"`
+	SourceAddressPrefix4  int    `bson:"source_address_prefix4" json:"source_address_prefix4"`
+	SourceAddressPrefix6  int    `bson:"source_address_prefix6" json:"source_address_prefix6"`
+}
+
+func NewCertificateProfile() *CertificateProfile {
+	return &CertificateProfile{
+		PermitX11Forwarding:   true,
+		PermitAgentForwarding: true,
+		PermitPortForwarding:  true,
+		PermitPty:             true,
+		PermitUserRc:          true,
+		SourceAddressPrefix4:  32,
+		SourceAddressPrefix6:  64,
+	}
+}
+
+func (p *CertificateProfile) Extensions() (extensions map[string]string) {
+	extensions = map[string]string{}
+
+	if p.PermitX11Forwarding {
+		extensions["permit-X11-forwarding"] = ""
+	}
+	if p.PermitAgentForwarding {
+		extensions["permit-agent-forwarding"] = ""
+	}
+	if p.PermitPortForwarding {
+		extensions["permit-port-forwarding"] = ""
+	}
+	if p.PermitPty {
+		extensions["permit-pty"] = ""
+	}
+	if p.PermitUserRc {
+		extensions["permit-user-rc"] = ""
+	}
+
+	return
+}
+
+func (p *CertificateProfile) CriticalOptions(agnt *agent.Agent) (
+	options map[string]string, err error) {
+
+	options = map[string]string{}
+
+	if p.ForceCommand != "" {
+		options["force-command"] = p.ForceCommand
+	}
+
+	if p.SourceAddress {
+		var clientIp net.IP
+		if agnt != nil {
+			clientIp = net.ParseIP(agnt.Ip)
+		}
+
+		if clientIp == nil {
+			err = &errortypes.ParseError{
+				errors.New("authority: Failed to parse client address"),
+			}
+			return
+		}
+
+		var network *net.IPNet
+		if clientIp.To4() != nil {
+			network = &net.IPNet{
+				IP:   clientIp.To4(),
+				Mask: net.CIDRMask(p.SourceAddressPrefix4, 32),
+			}
+		} else {
+			network = &net.IPNet{
+				IP:   clientIp,
+				Mask: net.CIDRMask(p.SourceAddressPrefix6, 128),
+			}
+		}
+		network.IP = network.IP.Mask(network.Mask)
+
+		options["source-address"] = network.String()
+	}
+
+	return
+}
+
+func (p *CertificateProfile) Validate() (errData *errortypes.ErrorData) {
+	p.ForceCommand = strings.TrimSpace(p.ForceCommand)
+
+	if strings.ContainsAny(p.ForceCommand, "\r\n") {
+		errData = &errortypes.ErrorData{
+			Error:   "force_command_invalid",
+			Message: "Force command cannot contain line breaks",
+		}
+		return
+	}
+
+	if p.SourceAddressPrefix4 == 0 {
+		p.SourceAddressPrefix4 = 32
+	}
+	if p.SourceAddressPrefix6 == 0 {
+		p.SourceAddressPrefix6 = 64
+	}
+
+	if p.SourceAddressPrefix4 < 8 || p.SourceAddressPrefix4 > 32 {
+		errData = &errortypes.ErrorData{
+			Error:   "source_address_prefix_invalid",
+			Message: "Source address IPv4 prefix must be between 8 and 32",
+		}
+		return
+	}
+
+	if p.SourceAddressPrefix6 < 16 || p.SourceAddressPrefix6 > 128 {
+		errData = &errortypes.ErrorData{
+			Error:   "source_address_prefix_invalid",
+			Message: "Source address IPv6 prefix must be between 16 and 128",
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,121 @@
+package authority
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+type RotatedKey struct {
+	PublicKey string    `bson:"public_key" json:"public_key"`
+	KeyAlg    string    `bson:"key_alg" json:"key_alg"`
+	Rotated   time.Time `bson:"rotated" json:"rotated"`
+	Retire    time.Time `bson:"retire" json:"retire"`
+}
+
+func (a *Authority) addRotatedKey(publicKey string, keyAlg string) {
+	publicKey = strings.TrimSpace(publicKey)
+	if publicKey == "" {
+		return
+	}
+
+	if a.RotatedKeys == nil {
+		a.RotatedKeys = []*RotatedKey{}
+	}
+
+	overlap := a.KeyOverlap
+	if overlap < 1 {
+		overlap = DefaultKeyOverlap
+	}
+
+	now := time.Now()
+	a.RotatedKeys = append(a.RotatedKeys, &RotatedKey{
+		PublicKey: publicKey,
+		KeyAlg:    keyAlg,
+		Rotated:   now,
+		Retire:    now.Add(time.Duration(overlap) * time.Hour),
+	})
+}
+
+func (a *Authority) GetPublicKeys() (publicKeys []string) {
+	publicKeys = []string{}
+
+	publicKey := strings.TrimSpace(a.PublicKey)
+	if publicKey != "" {
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	now := time.Now()
+	for _, key := range a.RotatedKeys {
+		if key == nil || now.After(key.Retire) {
+			continue
+		}
+
+		publicKeys = append(publicKeys, key.PublicKey)
+	}
+
+	return
+}
+
+func (a *Authority) RotateKey(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if a.Type != Local {
+		errData = &errortypes.ErrorData{
+			Error:   "rotate_unsupported",
+			Message: "Authority key must be rotated on the HSM",
+		}
+		return
+	}
+
+	publicKey := a.PublicKey
+	keyAlg := ""
+	if a.Info != nil {
+		keyAlg = a.Info.KeyAlg
+	}
+
+	err = a.GeneratePrivateKey()
+	if err != nil {
+		return
+	}
+
+	a.addRotatedKey(publicKey, keyAlg)
+
+	a.PublicKeyPem = ""
+	err = a.SetPublicKeyPem()
+	if err != nil {
+		return
+	}
+
+	a.RootCertificate = ""
+	err = a.CreateRootCertificate(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Authority) RetireKeys() (retired []*RotatedKey) {
+	retired = []*RotatedKey{}
+	rotatedKeys := []*RotatedKey{}
+
+	now := time.Now()
+	for _, key := range a.RotatedKeys {
+		if key == nil {
+			continue
+		}
+
+		if now.After(key.Retire) {
+			retired = append(retired, key)
+		} else {
+			rotatedKeys = append(rotatedKeys, key)
+		}
+	}
+
+	a.RotatedKeys = rotatedKeys
+
+	return
+}
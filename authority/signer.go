@@ -0,0 +1,118 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/rand"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"golang.org/x/crypto/ssh"
+)
+
+var signers = map[string]SignerHandler{}
+
+type Signer interface {
+	SignCertificate(db *database.Database, cert *ssh.Certificate) (
+		signed *ssh.Certificate, err error)
+	PrivateKey() (key crypto.Signer, err error)
+}
+
+type SignerHandler func(authr *Authority) (signer Signer, err error)
+
+func RegisterSigner(typ string, handler SignerHandler) {
+	signers[typ] = handler
+}
+
+type localSigner struct {
+	authr *Authority
+}
+
+func (s *localSigner) SignCertificate(db *database.Database,
+	cert *ssh.Certificate) (signed *ssh.Certificate, err error) {
+
+	privateKey, err := s.PrivateKey()
+	if err != nil {
+		return
+	}
+
+	sshSigner, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to load ssh signer"),
+		}
+		return
+	}
+
+	err = cert.SignCert(rand.Reader, sshSigner)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to sign certificate"),
+		}
+		return
+	}
+
+	signed = cert
+
+	return
+}
+
+func (s *localSigner) PrivateKey() (key crypto.Signer, err error) {
+	key, err = s.authr.getPrivateKey()
+	return
+}
+
+func newLocalSigner(authr *Authority) (signer Signer, err error) {
+	signer = &localSigner{
+		authr: authr,
+	}
+	return
+}
+
+type hsmSigner struct {
+	authr *Authority
+}
+
+func (s *hsmSigner) SignCertificate(db *database.Database,
+	cert *ssh.Certificate) (signed *ssh.Certificate, err error) {
+
+	signed, err = s.authr.signCertificateHsm(db, cert)
+	return
+}
+
+func (s *hsmSigner) PrivateKey() (key crypto.Signer, err error) {
+	err = &errortypes.UnknownError{
+		errors.New("authority: Private key not available on HSM"),
+	}
+	return
+}
+
+func newHsmSigner(authr *Authority) (signer Signer, err error) {
+	signer = &hsmSigner{
+		authr: authr,
+	}
+	return
+}
+
+func (a *Authority) GetSigner() (signer Signer, err error) {
+	handler, ok := signers[a.Type]
+	if !ok {
+		err = &errortypes.UnknownError{
+			errors.Newf("authority: Unknown authority signer '%s'", a.Type),
+		}
+		return
+	}
+
+	signer, err = handler(a)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func init() {
+	RegisterSigner(Local, newLocalSigner)
+	RegisterSigner(Pkcs11, newLocalSigner)
+	RegisterSigner(PritunlHsm, newHsmSigner)
+}
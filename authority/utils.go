@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -11,6 +12,7 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"hash/fnv"
 	"net"
 	"strings"
 
@@ -122,6 +124,12 @@ func parseSubnetMatch(subnetMatch string) (
 	return
 }
 
+func GenerateSerial() uint64 {
+	serialHash := fnv.New64a()
+	serialHash.Write([]byte(primitive.NewObjectID().Hex()))
+	return serialHash.Sum64()
+}
+
 func MarshalCertificate(cert *ssh.Certificate, comment string) []byte {
 	b := &bytes.Buffer{}
 	b.WriteString(cert.Type())
@@ -210,6 +218,42 @@ func GenerateEcKey() (encodedPriv, encodedPub []byte, err error) {
 	return
 }
 
+func GenerateEd25519Key() (encodedPriv, encodedPub []byte, err error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to generate ed25519 key"),
+		}
+		return
+	}
+
+	pubKey, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse ed25519 key"),
+		}
+		return
+	}
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal ed25519 key"),
+		}
+		return
+	}
+
+	block := &pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: keyBytes,
+	}
+
+	encodedPriv = pem.EncodeToMemory(block)
+	encodedPub = MarshalPublicKey(pubKey)
+
+	return
+}
+
 func ParsePemKey(data string) (key crypto.PrivateKey, err error) {
 	block, _ := pem.Decode([]byte(data))
 	if block == nil {
@@ -238,6 +282,23 @@ func ParsePemKey(data string) (key crypto.PrivateKey, err error) {
 			return
 		}
 		break
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "authority: Failed to parse pkcs8 key"),
+			}
+			return
+		}
+
+		if _, ok := key.(ed25519.PrivateKey); !ok {
+			key = nil
+			err = &errortypes.ParseError{
+				errors.New("authority: Unsupported pkcs8 key type"),
+			}
+			return
+		}
+		break
 	default:
 		err = &errortypes.ParseError{
 			errors.Newf("authority: Unknown key type '%s'", block.Type),
@@ -2,9 +2,9 @@ package bastion
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -16,24 +16,20 @@ import (
 	"github.com/pritunl/pritunl-zero/errortypes"
 	"github.com/pritunl/pritunl-zero/settings"
 	"github.com/pritunl/pritunl-zero/ssh"
-	"github.com/pritunl/pritunl-zero/utils"
 )
 
 type Bastion struct {
 	Authority  primitive.ObjectID
-	Container  string
 	authr      *authority.Authority
+	server     *server
+	listener   net.Listener
 	certExpire time.Time
 	state      bool
 	kill       bool
-	path       string
+	lock       sync.Mutex
 }
 
 func (b *Bastion) syncCert() {
-	defer func() {
-		os.RemoveAll(b.path)
-	}()
-
 	for {
 		if !b.state {
 			return
@@ -56,24 +52,13 @@ func (b *Bastion) syncCert() {
 
 func (b *Bastion) wait() {
 	defer func() {
-		os.RemoveAll(b.path)
 		b.state = false
 		delete(state, b.Authority)
 	}()
 
-	output, err := utils.ExecOutput("", "docker", "wait", b.Container)
-	if b.state && err != nil {
-		err = &errortypes.RequestError{
-			errors.Wrapf(err, "bastion: Failed to exec docker"),
-		}
-		return
-	}
-
-	output = strings.TrimSpace(output)
-	if output != "0" && output != "137" {
-		logrus.WithFields(logrus.Fields{
-			"exit_code": output,
-		}).Error("bastion: Bastion process error")
+	err := b.server.Serve(b.listener)
+	if !b.kill && err != nil {
+		b.failure(nil, err)
 	}
 }
 
@@ -87,25 +72,20 @@ func (b *Bastion) renewHost(db *database.Database) (err error) {
 		"authority_id": b.Authority.Hex(),
 	}).Info("bastion: Renewing bastion host certificate")
 
-	cert, e := ssh.NewBastionHostCertificate(db,
+	cert, err := ssh.NewBastionHostCertificate(db,
 		b.authr.ProxyHostname, b.authr.ProxyPublicKey, b.authr)
-	if e != nil {
-		b.state = false
-		os.RemoveAll(b.path)
-		err = e
+	if err != nil {
 		return
 	}
 
-	hostCertPath := filepath.Join(b.path, "ssh_host_rsa_key-cert.pub")
-
 	if len(cert.Certificates) == 0 || len(cert.CertificatesInfo) == 0 {
 		err = &errortypes.UnknownError{
-			errors.Wrapf(err, "bastion: Missing host certificate"),
+			errors.New("bastion: Missing host certificate"),
 		}
 		return
 	}
 
-	err = utils.CreateWrite(hostCertPath, cert.Certificates[0], 0644)
+	err = b.server.SetHostCertificate(cert.Certificates[0])
 	if err != nil {
 		return
 	}
@@ -118,22 +98,38 @@ func (b *Bastion) renewHost(db *database.Database) (err error) {
 func (b *Bastion) Start(db *database.Database,
 	authr *authority.Authority) (err error) {
 
+	err = b.start(db, authr)
+	if err != nil {
+		b.failure(db, err)
+		return
+	}
+
+	b.running(db)
+
+	return
+}
+
+func (b *Bastion) start(db *database.Database,
+	authr *authority.Authority) (err error) {
+
 	logrus.WithFields(logrus.Fields{
 		"authority_id": b.Authority.Hex(),
 	}).Info("bastion: Starting bastion server")
 
-	if b.state || b.path != "" {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state || b.listener != nil {
 		err = &errortypes.UnknownError{
-			errors.Wrapf(err, "bastion: Bastion server already running"),
+			errors.New("bastion: Bastion server already running"),
 		}
 		return
 	}
 
 	b.authr = authr
-	b.path = utils.GetTempPath()
 
 	if authr.ProxyPublicKey == "" || authr.ProxyPrivateKey == "" {
-		err = authr.GenerateRsaProxyPrivateKey()
+		err = authr.GenerateProxyPrivateKey()
 		if err != nil {
 			return
 		}
@@ -147,60 +143,43 @@ func (b *Bastion) Start(db *database.Database,
 		}
 	}
 
-	b.state = true
-
-	err = utils.ExistsMkdir(b.path, 0755)
+	b.server, err = newServer(authr)
 	if err != nil {
-		b.state = false
-		os.RemoveAll(b.path)
 		return
 	}
 
 	if !settings.System.DisableBastionHostCertificates {
 		err = b.renewHost(db)
 		if err != nil {
-			b.state = false
-			os.RemoveAll(b.path)
 			return
 		}
 	}
 
-	output, err := utils.ExecOutput("",
-		"docker",
-		"run",
-		"--rm",
-		"-d",
-		"-u", "bastion",
-		"--name", DockerGetName(authr.Id),
-		"-p", fmt.Sprintf("%d:9722", authr.ProxyPort),
-		"-v", fmt.Sprintf("%s:/ssh_mount", b.path),
-		"-e", fmt.Sprintf(
-			"BASTION_TRUSTED=%s", authr.PublicKey),
-		"-e", fmt.Sprintf(
-			"BASTION_HOST_KEY=%s", authr.ProxyPrivateKey),
-		"-e", fmt.Sprintf(
-			"BASTION_HOST_PUB_KEY=%s", authr.ProxyPublicKey),
-		settings.System.BastionDockerImage,
-	)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", authr.ProxyPort))
 	if err != nil {
-		b.state = false
-		os.RemoveAll(b.path)
-
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "bastion: Failed to listen on bastion port"),
+		}
 		return
 	}
 
-	b.Container = strings.TrimSpace(output)
+	b.listener = listener
+	b.state = true
 
 	if !settings.System.DisableBastionHostCertificates {
 		go b.syncCert()
 	}
 
 	go b.wait()
+	go b.healthCheck()
 
 	return
 }
 
 func (b *Bastion) Stop() (err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
 	if b.kill {
 		return
 	}
@@ -210,19 +189,18 @@ func (b *Bastion) Stop() (err error) {
 		"authority_id": b.Authority.Hex(),
 	}).Info("bastion: Stopping bastion server")
 
-	_, err = utils.ExecOutputLogged(nil,
-		"docker", "stop", "-t", "3", b.Container)
-	if err != nil {
-		return
+	if b.listener != nil {
+		err = b.listener.Close()
+		if err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(err, "bastion: Failed to close bastion listener"),
+			}
+		}
 	}
 
-	go func() {
-		time.Sleep(15 * time.Second)
-
-		if b.state {
-			utils.ExecOutputLogged(nil, "docker", "kill", b.Container)
-		}
-	}()
+	if b.server != nil {
+		b.server.Close()
+	}
 
 	return
 }
@@ -236,7 +214,14 @@ func (b *Bastion) Diff(authr *authority.Authority) bool {
 		b.authr.ProxyPrivateKey != authr.ProxyPrivateKey ||
 		b.authr.HostCertificates != authr.HostCertificates ||
 		b.authr.ProxyPort != authr.ProxyPort ||
-		b.authr.PublicKey != authr.PublicKey {
+		b.authr.PublicKey != authr.PublicKey ||
+		b.authr.HostDomain != authr.HostDomain ||
+		strings.Join(b.authr.HostMatches, ",") !=
+			strings.Join(authr.HostMatches, ",") ||
+		strings.Join(b.authr.HostSubnets, ",") !=
+			strings.Join(authr.HostSubnets, ",") ||
+		strings.Join(b.authr.GetPublicKeys(), "\n") !=
+			strings.Join(authr.GetPublicKeys(), "\n") {
 
 		return true
 	}
@@ -0,0 +1,25 @@
+package bastion
+
+import (
+	"time"
+)
+
+const (
+	Principal = "bastion"
+
+	healthInterval    = 10 * time.Second
+	healthTimeout     = 5 * time.Second
+	healthFailures    = 3
+	statusInterval    = 30 * time.Second
+	restartBackoffMin = 2 * time.Second
+	restartBackoffMax = 5 * time.Minute
+	restartReset      = 10 * time.Minute
+	restartFailed     = 5
+
+	recordingChunkSize = 256 * 1024
+	recordingMaxSize   = 64 * 1024 * 1024
+)
+
+var supportedCriticalOptions = []string{
+	"force-command",
+}
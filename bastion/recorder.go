@@ -0,0 +1,137 @@
+package bastion
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/ssh"
+)
+
+type recordingHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Env       map[string]string `json:"env"`
+}
+
+type recorder struct {
+	sess      *ssh.Session
+	start     time.Time
+	buf       bytes.Buffer
+	index     int
+	size      int
+	truncated bool
+	lock      sync.Mutex
+}
+
+func (r *recorder) flush() {
+	if r.buf.Len() == 0 {
+		return
+	}
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	chunk := &ssh.RecordingChunk{
+		SessionId: r.sess.Id,
+		UserId:    r.sess.UserId,
+		Index:     r.index,
+		Data:      r.buf.String(),
+		Timestamp: time.Now(),
+	}
+	r.index += 1
+	r.buf.Reset()
+
+	err := chunk.Insert(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"session_id": r.sess.Id.Hex(),
+			"error":      err,
+		}).Error("bastion: Failed to store session recording")
+	}
+}
+
+func (r *recorder) event(typ string, data []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.truncated {
+		return
+	}
+
+	evt, err := json.Marshal([]interface{}{
+		time.Since(r.start).Seconds(),
+		typ,
+		string(data),
+	})
+	if err != nil {
+		return
+	}
+
+	if r.size+len(evt)+1 > recordingMaxSize {
+		r.truncated = true
+		return
+	}
+
+	r.buf.Write(evt)
+	r.buf.WriteByte('\n')
+	r.size += len(evt) + 1
+
+	if r.buf.Len() >= recordingChunkSize {
+		r.flush()
+	}
+}
+
+func (r *recorder) Write(p []byte) (n int, err error) {
+	n = len(p)
+	r.event("o", p)
+	return
+}
+
+func (r *recorder) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.flush()
+}
+
+func newRecorder(sess *ssh.Session, pty *ptyRequest,
+	command string) (rec *recorder) {
+
+	rec = &recorder{
+		sess:  sess,
+		start: time.Now(),
+	}
+
+	width := int(pty.Columns)
+	if width == 0 {
+		width = 80
+	}
+	height := int(pty.Rows)
+	if height == 0 {
+		height = 24
+	}
+
+	header, _ := json.Marshal(&recordingHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: rec.start.Unix(),
+		Command:   command,
+		Env: map[string]string{
+			"TERM": pty.Term,
+		},
+	})
+
+	rec.buf.Write(header)
+	rec.buf.WriteByte('\n')
+	rec.size = rec.buf.Len()
+
+	return
+}
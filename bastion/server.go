@@ -0,0 +1,451 @@
+package bastion
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/revocation"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	keyIdExtension       = "key-id@pritunl.com"
+	serialExtension      = "serial@pritunl.com"
+	portForwardExtension = "permit-port-forwarding"
+	sessionNotice        = "This bastion only supports forwarded connections, " +
+		"use it as a jump host with ProxyJump\r\n"
+)
+
+type directTcpip struct {
+	Host     string
+	Port     uint32
+	OrigHost string
+	OrigPort uint32
+}
+
+type ptyRequest struct {
+	Term    string
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+	Modes   string
+}
+
+type execRequest struct {
+	Command string
+}
+
+type exitStatus struct {
+	Status uint32
+}
+
+type server struct {
+	authr      *authority.Authority
+	trusted    []ssh.PublicKey
+	hostSigner ssh.Signer
+	config     *ssh.ServerConfig
+	conns      map[*ssh.ServerConn]bool
+	lock       sync.Mutex
+}
+
+func (s *server) isUserAuthority(key ssh.PublicKey) bool {
+	keyBytes := key.Marshal()
+	for _, trusted := range s.trusted {
+		if bytes.Equal(trusted.Marshal(), keyBytes) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) isRevoked(cert *ssh.Certificate) bool {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	revoked, err := revocation.IsRevoked(db, s.authr.Id,
+		strconv.FormatUint(cert.Serial, 10), cert.KeyId,
+		string(ssh.MarshalAuthorizedKey(cert.Key)))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"key_id":       cert.KeyId,
+			"serial":       cert.Serial,
+			"error":        err,
+		}).Error("bastion: Failed to check certificate revocation")
+		return true
+	}
+
+	return revoked
+}
+
+func (s *server) authenticate(conn ssh.ConnMetadata, key ssh.PublicKey) (
+	perms *ssh.Permissions, err error) {
+
+	if conn.User() != Principal {
+		err = &errortypes.AuthenticationError{
+			errors.New("bastion: Invalid bastion user"),
+		}
+		return
+	}
+
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.AuthenticationError{
+			errors.New("bastion: User certificate required"),
+		}
+		return
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority:          s.isUserAuthority,
+		IsRevoked:                s.isRevoked,
+		SupportedCriticalOptions: supportedCriticalOptions,
+	}
+
+	perms, err = checker.Authenticate(conn, key)
+	if err != nil {
+		err = &errortypes.AuthenticationError{
+			errors.Wrap(err, "bastion: Certificate authentication failed"),
+		}
+		return
+	}
+
+	extensions := map[string]string{}
+	for name, value := range perms.Extensions {
+		extensions[name] = value
+	}
+	extensions[keyIdExtension] = cert.KeyId
+	extensions[serialExtension] = strconv.FormatUint(cert.Serial, 10)
+
+	perms = &ssh.Permissions{
+		CriticalOptions: perms.CriticalOptions,
+		Extensions:      extensions,
+	}
+
+	return
+}
+
+func (s *server) newConfig() *ssh.ServerConfig {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: s.authenticate,
+	}
+	config.AddHostKey(s.hostSigner)
+	return config
+}
+
+func (s *server) SetHostCertificate(certStr string) (err error) {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certStr))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to parse host certificate"),
+		}
+		return
+	}
+
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("bastion: Invalid host certificate"),
+		}
+		return
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, s.hostSigner)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to load host certificate"),
+		}
+		return
+	}
+
+	config := s.newConfig()
+	config.AddHostKey(certSigner)
+
+	s.lock.Lock()
+	s.config = config
+	s.lock.Unlock()
+
+	return
+}
+
+func (s *server) getConfig() *ssh.ServerConfig {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.config
+}
+
+func (s *server) Serve(listener net.Listener) (err error) {
+	for {
+		conn, e := listener.Accept()
+		if e != nil {
+			if ne, ok := e.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "bastion: Failed to accept connection"),
+			}
+			return
+		}
+
+		go s.handleConn(conn)
+	}
+}
+
+func (s *server) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *server) handleConn(netConn net.Conn) {
+	netConn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	conn, chans, reqs, err := ssh.NewServerConn(netConn, s.getConfig())
+	if err != nil {
+		netConn.Close()
+
+		if err == io.EOF {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"remote":       netConn.RemoteAddr().String(),
+			"error":        err,
+		}).Info("bastion: Bastion connection rejected")
+		return
+	}
+	defer conn.Close()
+
+	netConn.SetDeadline(time.Time{})
+
+	s.lock.Lock()
+	s.conns[conn] = true
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+	}()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		switch newChan.ChannelType() {
+		case "direct-tcpip":
+			go s.handleDirect(conn, newChan)
+			break
+		case "session":
+			go s.handleSession(conn, newChan)
+			break
+		default:
+			newChan.Reject(ssh.Prohibited, "channel type not allowed")
+		}
+	}
+}
+
+func (s *server) handleDirect(conn *ssh.ServerConn, newChan ssh.NewChannel) {
+	data := &directTcpip{}
+	err := ssh.Unmarshal(newChan.ExtraData(), data)
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed, "invalid forward request")
+		return
+	}
+
+	if _, ok := conn.Permissions.Extensions[portForwardExtension]; !ok {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"remote":       conn.RemoteAddr().String(),
+			"key_id":       conn.Permissions.Extensions[keyIdExtension],
+			"host":         data.Host,
+			"port":         data.Port,
+		}).Warn("bastion: Bastion forward without port forwarding permit")
+
+		newChan.Reject(ssh.Prohibited, "port forwarding not permitted")
+		return
+	}
+
+	host, ok := matchHost(s.authr, data.Host)
+	if !ok {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": s.authr.Id.Hex(),
+			"remote":       conn.RemoteAddr().String(),
+			"host":         data.Host,
+			"port":         data.Port,
+		}).Warn("bastion: Bastion forward to host not allowed")
+
+		newChan.Reject(ssh.Prohibited, "destination not allowed")
+		return
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(int(data.Port)))
+
+	target, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed,
+			fmt.Sprintf("failed to connect to %s", addr))
+		return
+	}
+	defer target.Close()
+
+	channel, chanReqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	go ssh.DiscardRequests(chanReqs)
+
+	sess := startSession(
+		s.authr.Id,
+		forwardSession,
+		conn.Permissions.Extensions[keyIdExtension],
+		conn.Permissions.Extensions[serialExtension],
+		conn.RemoteAddr().String(),
+		data.Host,
+		int(data.Port),
+	)
+
+	var sent int64
+	var received int64
+	waiter := sync.WaitGroup{}
+	waiter.Add(2)
+
+	go func() {
+		defer waiter.Done()
+		sent, _ = io.Copy(target, channel)
+		if tcpConn, ok := target.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		}
+	}()
+
+	go func() {
+		defer waiter.Done()
+		received, _ = io.Copy(channel, target)
+		channel.CloseWrite()
+	}()
+
+	waiter.Wait()
+
+	endSession(sess, sent, received, nil)
+}
+
+func (s *server) handleSession(conn *ssh.ServerConn,
+	newChan ssh.NewChannel) {
+
+	channel, reqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	pty := &ptyRequest{
+		Term: "xterm",
+	}
+
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			e := ssh.Unmarshal(req.Payload, pty)
+			req.Reply(e == nil, nil)
+			break
+		case "env", "window-change":
+			req.Reply(true, nil)
+			break
+		case "shell", "exec":
+			req.Reply(true, nil)
+
+			command := ""
+			if req.Type == "exec" {
+				execReq := &execRequest{}
+				if ssh.Unmarshal(req.Payload, execReq) == nil {
+					command = execReq.Command
+				}
+			}
+
+			sess := startSession(
+				s.authr.Id,
+				interactiveSession,
+				conn.Permissions.Extensions[keyIdExtension],
+				conn.Permissions.Extensions[serialExtension],
+				conn.RemoteAddr().String(),
+				"",
+				0,
+			)
+
+			var rec *recorder
+			var out io.Writer = channel
+			if sess != nil {
+				rec = newRecorder(sess, pty, command)
+				out = io.MultiWriter(channel, rec)
+			}
+
+			n, _ := io.WriteString(out, sessionNotice)
+
+			channel.SendRequest("exit-status", false,
+				ssh.Marshal(&exitStatus{
+					Status: 1,
+				}))
+
+			endSession(sess, 0, int64(n), rec)
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func newServer(authr *authority.Authority) (srv *server, err error) {
+	privateKey, err := authority.ParsePemKey(authr.ProxyPrivateKey)
+	if err != nil {
+		return
+	}
+
+	hostSigner, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bastion: Failed to parse host key"),
+		}
+		return
+	}
+
+	trusted := []ssh.PublicKey{}
+	for _, publicKey := range authr.GetPublicKeys() {
+		pubKey, _, _, _, e := ssh.ParseAuthorizedKey([]byte(publicKey))
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "bastion: Failed to parse authority key"),
+			}
+			return
+		}
+
+		trusted = append(trusted, pubKey)
+	}
+
+	srv = &server{
+		authr:      authr,
+		trusted:    trusted,
+		hostSigner: hostSigner,
+		conns:      map[*ssh.ServerConn]bool{},
+	}
+	srv.config = srv.newConfig()
+
+	return
+}
@@ -0,0 +1,147 @@
+package bastion
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/authority"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestSigner(t *testing.T) (signer ssh.Signer) {
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err = ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func dialTestDirect(t *testing.T, extensions map[string]string,
+	addr string) (err error) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	srv := &server{
+		authr: &authority.Authority{
+			Id: primitive.NewObjectID(),
+		},
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (
+			*ssh.Permissions, error) {
+
+			return &ssh.Permissions{
+				Extensions: extensions,
+			}, nil
+		},
+	}
+	config.AddHostKey(newTestSigner(t))
+
+	go func() {
+		netConn, e := listener.Accept()
+		if e != nil {
+			return
+		}
+		defer netConn.Close()
+
+		conn, chans, reqs, e := ssh.NewServerConn(netConn, config)
+		if e != nil {
+			return
+		}
+		defer conn.Close()
+
+		go ssh.DiscardRequests(reqs)
+
+		for newChan := range chans {
+			srv.handleDirect(conn, newChan)
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", listener.Addr().String(),
+		&ssh.ClientConfig{
+			User: Principal,
+			Auth: []ssh.AuthMethod{
+				ssh.PublicKeys(newTestSigner(t)),
+			},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := client.Dial("tcp", addr)
+	if err == nil {
+		conn.Close()
+	}
+
+	return
+}
+
+func TestHandleDirectPortForwarding(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions map[string]string
+		message    string
+	}{
+		{
+			"missing_extensions",
+			nil,
+			"port forwarding not permitted",
+		},
+		{
+			"missing_permit",
+			map[string]string{
+				"permit-pty":   "",
+				keyIdExtension: "test",
+			},
+			"port forwarding not permitted",
+		},
+		{
+			"permitted",
+			map[string]string{
+				portForwardExtension: "",
+				keyIdExtension:       "test",
+			},
+			"destination not allowed",
+		},
+	}
+
+	for _, test := range tests {
+		err := dialTestDirect(t, test.extensions, "host.example.com:22")
+		if err == nil {
+			t.Errorf("Expected forward rejection %s", test.name)
+			continue
+		}
+
+		chanErr, ok := err.(*ssh.OpenChannelError)
+		if !ok {
+			t.Errorf("Wrong forward error %s: %s", test.name, err)
+			continue
+		}
+
+		if chanErr.Reason != ssh.Prohibited {
+			t.Errorf("Wrong forward reject reason %s: %s",
+				test.name, chanErr.Reason)
+		}
+
+		if chanErr.Message != test.message {
+			t.Errorf("Wrong forward reject message %s: %s",
+				test.name, chanErr.Message)
+		}
+	}
+}
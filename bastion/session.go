@@ -0,0 +1,82 @@
+package bastion
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/ssh"
+)
+
+const (
+	forwardSession     = ssh.Forward
+	interactiveSession = ssh.Interactive
+)
+
+func startSession(authrId primitive.ObjectID, typ, keyId, serial,
+	sourceAddr, targetHost string, targetPort int) (sess *ssh.Session) {
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	sess, err := ssh.NewSession(db, typ, authrId, keyId, serial,
+		sourceAddr, targetHost, targetPort)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"authority_id": authrId.Hex(),
+			"key_id":       keyId,
+			"serial":       serial,
+			"error":        err,
+		}).Error("bastion: Failed to store bastion session")
+		sess = nil
+		return
+	}
+
+	return
+}
+
+func endSession(sess *ssh.Session, sent, received int64,
+	rec *recorder) {
+
+	if sess == nil {
+		return
+	}
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	sess.End = time.Now()
+	sess.BytesSent = sent
+	sess.BytesReceived = received
+
+	fields := set.NewSet("end", "bytes_sent", "bytes_received")
+
+	if rec != nil {
+		rec.Close()
+		sess.Recorded = true
+		fields.Add("recorded")
+	}
+
+	err := sess.CommitFields(db, fields)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"session_id": sess.Id.Hex(),
+			"error":      err,
+		}).Error("bastion: Failed to store bastion session")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id":     sess.Id.Hex(),
+		"type":           sess.Type,
+		"key_id":         sess.KeyId,
+		"serial":         sess.Serial,
+		"source_address": sess.SourceAddress,
+		"target_host":    sess.TargetHost,
+		"target_port":    sess.TargetPort,
+		"bytes_sent":     sent,
+		"bytes_received": received,
+		"duration":       sess.End.Sub(sess.Start).String(),
+	}).Info("bastion: Bastion session closed")
+}
@@ -0,0 +1,227 @@
+package bastion
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/event"
+)
+
+type supervisor struct {
+	status    string
+	restarts  int
+	next      time.Time
+	lastError string
+}
+
+var (
+	supervisors     = map[primitive.ObjectID]*supervisor{}
+	supervisorsLock = sync.Mutex{}
+)
+
+func getSupervisor(authrId primitive.ObjectID) (sup *supervisor) {
+	sup = supervisors[authrId]
+	if sup == nil {
+		sup = &supervisor{}
+		supervisors[authrId] = sup
+	}
+	return
+}
+
+func backoff(restarts int) (delay time.Duration) {
+	delay = restartBackoffMin
+	for i := 1; i < restarts && delay < restartBackoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > restartBackoffMax {
+		delay = restartBackoffMax
+	}
+
+	return
+}
+
+func probe(port int) (err error) {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	conn, err := net.DialTimeout("tcp", addr, healthTimeout)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "bastion: Health check connection failed"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(healthTimeout))
+
+	banner, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "bastion: Health check banner read failed"),
+		}
+		return
+	}
+
+	if !strings.HasPrefix(banner, "SSH-") {
+		err = &errortypes.RequestError{
+			errors.New("bastion: Health check banner invalid"),
+		}
+		return
+	}
+
+	return
+}
+
+func Ready(authrId primitive.ObjectID) bool {
+	supervisorsLock.Lock()
+	defer supervisorsLock.Unlock()
+
+	sup := supervisors[authrId]
+	if sup == nil {
+		return true
+	}
+
+	return !time.Now().Before(sup.next)
+}
+
+func (b *Bastion) setStatus(db *database.Database, status string,
+	restarts int, lastError string) {
+
+	if db == nil {
+		db = database.GetDatabase()
+		defer db.Close()
+	}
+
+	coll := db.Authorities()
+
+	_, err := coll.UpdateOne(db, &bson.M{
+		"_id": b.Authority,
+	}, &bson.M{
+		"$set": &bson.M{
+			"bastion_status":    status,
+			"bastion_restarts":  restarts,
+			"bastion_error":     lastError,
+			"bastion_timestamp": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		logrus.WithFields(logrus.Fields{
+			"authority_id": b.Authority.Hex(),
+			"error":        err,
+		}).Error("bastion: Failed to update bastion status")
+		return
+	}
+
+	supervisorsLock.Lock()
+	sup := getSupervisor(b.Authority)
+	changed := sup.status != status
+	sup.status = status
+	supervisorsLock.Unlock()
+
+	if changed {
+		event.PublishDispatch(db, "authority.change")
+	}
+}
+
+func (b *Bastion) running(db *database.Database) {
+	supervisorsLock.Lock()
+	sup := getSupervisor(b.Authority)
+	restarts := sup.restarts
+	lastError := sup.lastError
+	supervisorsLock.Unlock()
+
+	b.setStatus(db, authority.BastionRunning, restarts, lastError)
+}
+
+func (b *Bastion) failure(db *database.Database, err error) {
+	supervisorsLock.Lock()
+	sup := getSupervisor(b.Authority)
+	sup.restarts += 1
+	delay := backoff(sup.restarts)
+	sup.next = time.Now().Add(delay)
+	sup.lastError = err.Error()
+	restarts := sup.restarts
+	lastError := sup.lastError
+	supervisorsLock.Unlock()
+
+	status := authority.BastionRestarting
+	if restarts >= restartFailed {
+		status = authority.BastionFailed
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"authority_id": b.Authority.Hex(),
+		"restarts":     restarts,
+		"backoff":      delay.String(),
+		"error":        err,
+	}).Error("bastion: Bastion server failed, scheduling restart")
+
+	b.setStatus(db, status, restarts, lastError)
+}
+
+func (b *Bastion) healthCheck() {
+	failures := 0
+	started := time.Now()
+	lastStatus := time.Now()
+	reset := false
+
+	for {
+		time.Sleep(healthInterval)
+
+		if !b.state || b.kill {
+			return
+		}
+
+		err := probe(b.authr.ProxyPort)
+		if err != nil {
+			failures += 1
+
+			logrus.WithFields(logrus.Fields{
+				"authority_id": b.Authority.Hex(),
+				"failures":     failures,
+				"error":        err,
+			}).Warn("bastion: Bastion health check failed")
+
+			if failures >= healthFailures {
+				b.failure(nil, err)
+
+				err = b.Stop()
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"authority_id": b.Authority.Hex(),
+						"error":        err,
+					}).Error("bastion: Failed to stop unhealthy bastion")
+				}
+				return
+			}
+			continue
+		}
+		failures = 0
+
+		if !reset && time.Since(started) > restartReset {
+			reset = true
+
+			supervisorsLock.Lock()
+			getSupervisor(b.Authority).restarts = 0
+			supervisorsLock.Unlock()
+		}
+
+		if time.Since(lastStatus) >= statusInterval {
+			lastStatus = time.Now()
+			b.running(nil)
+		}
+	}
+}
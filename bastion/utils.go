@@ -1,68 +1,50 @@
 package bastion
 
 import (
-	"fmt"
+	"net"
 	"strings"
 
-	"github.com/dropbox/godropbox/errors"
-	"github.com/pritunl/pritunl-zero/errortypes"
-
-	"github.com/pritunl/mongo-go-driver/bson/primitive"
-	"github.com/pritunl/pritunl-zero/utils"
+	"github.com/pritunl/pritunl-zero/authority"
 )
 
-func DockerMatchContainer(a, b string) bool {
-	if len(b) > len(a) {
-		a, b = b, a
-	}
-	return strings.HasPrefix(a, b)
-}
-
-func DockerGetName(authrId primitive.ObjectID) string {
-	return fmt.Sprintf("pritunl-bastion-%s", authrId.Hex())
-}
-
-func DockerGetRunning() (running map[string]primitive.ObjectID, err error) {
-	running = map[string]primitive.ObjectID{}
+func matchHost(authr *authority.Authority, host string) (
+	addr string, ok bool) {
 
-	output, err := utils.ExecOutput("",
-		"docker", "ps", "-a", "--format", "{{.Names}}:{{.ID}}")
-	if err != nil {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
 		return
 	}
 
-	for _, line := range strings.Split(output, "\n") {
-		fields := strings.SplitN(strings.TrimSpace(line), ":", 2)
-		if len(fields) != 2 {
-			continue
-		}
-
-		name := fields[0]
-		containerId := fields[1]
-
-		if len(name) != 40 || !strings.HasPrefix(name, "pritunl-bastion-") {
-			continue
-		}
+	if authr.MatchHostPattern(host) {
+		addr = host
+		ok = true
+		return
+	}
 
-		authrId, e := primitive.ObjectIDFromHex(name[16:])
-		if e != nil {
-			err = &errortypes.ParseError{
-				errors.Wrap(e, "bastion: Failed to parse ObjectID"),
-			}
-			return
+	if ip := net.ParseIP(host); ip != nil {
+		if authr.MatchHostSubnet(ip) {
+			addr = ip.String()
+			ok = true
 		}
-
-		running[containerId] = authrId
+		return
 	}
 
-	return
-}
+	if len(authr.HostSubnets) == 0 {
+		return
+	}
 
-func DockerRemove(containerId string) (err error) {
-	_, err = utils.ExecOutputLogged(nil, "docker", "rm", "-f", containerId)
+	ips, err := net.LookupIP(host)
 	if err != nil {
 		return
 	}
 
+	for _, ip := range ips {
+		if authr.MatchHostSubnet(ip) {
+			addr = ip.String()
+			ok = true
+			return
+		}
+	}
+
 	return
 }
@@ -0,0 +1,362 @@
+package breakglass
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/revocation"
+	"github.com/pritunl/pritunl-zero/session"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+type Account struct {
+	Id             primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name           string               `bson:"name" json:"name"`
+	Comment        string               `bson:"comment" json:"comment"`
+	UserId         primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	Authorities    []primitive.ObjectID `bson:"authorities" json:"authorities"`
+	Shares         int                  `bson:"shares" json:"shares"`
+	Threshold      int                  `bson:"threshold" json:"threshold"`
+	Duration       int                  `bson:"duration" json:"duration"`
+	SecretHash     string               `bson:"secret_hash" json:"-"`
+	Sealed         bool                 `bson:"sealed" json:"sealed"`
+	SealedAt       time.Time            `bson:"sealed_at" json:"sealed_at"`
+	Unlocked       bool                 `bson:"unlocked" json:"unlocked"`
+	UnlockedAt     time.Time            `bson:"unlocked_at" json:"unlocked_at"`
+	Expires        time.Time            `bson:"expires" json:"expires"`
+	FailedAttempts int                  `bson:"failed_attempts" json:"failed_attempts"`
+}
+
+func hashSecret(secret []byte) string {
+	hash := sha512.Sum512(secret)
+	return base64.RawStdEncoding.EncodeToString(hash[:])
+}
+
+func (a *Account) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_name_invalid",
+			Message: "Break-glass account name is not valid",
+		}
+		return
+	}
+
+	if a.Authorities == nil {
+		a.Authorities = []primitive.ObjectID{}
+	}
+
+	if a.UserId.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_user_invalid",
+			Message: "Break-glass account user is not valid",
+		}
+		return
+	}
+
+	usr, err := user.Get(db, a.UserId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "breakglass_user_invalid",
+				Message: "Break-glass account user is not valid",
+			}
+		}
+		return
+	}
+
+	if usr.Type == user.Api {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_user_invalid",
+			Message: "Break-glass account user cannot be an API user",
+		}
+		return
+	}
+
+	if a.Threshold < 2 || a.Shares < a.Threshold || a.Shares > MaxShares {
+		errData = &errortypes.ErrorData{
+			Error: "breakglass_shares_invalid",
+			Message: "Break-glass account must require at least two " +
+				"shares and have no more than ten shares",
+		}
+		return
+	}
+
+	if a.Duration == 0 {
+		a.Duration = DefaultDuration
+	}
+
+	if a.Duration < 1 || a.Duration > MaxDuration {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_duration_invalid",
+			Message: "Break-glass account duration is not valid",
+		}
+		return
+	}
+
+	return
+}
+
+func (a *Account) disableUser(db *database.Database) (err error) {
+	usr, err := user.Get(db, a.UserId)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	usr.Disabled = true
+	usr.ActiveUntil = time.Time{}
+
+	err = usr.CommitFields(db, set.NewSet("disabled", "active_until"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Account) Seal(db *database.Database) (parts []string,
+	errData *errortypes.ErrorData, err error) {
+
+	if a.Unlocked {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_unlocked",
+			Message: "Break-glass account must be locked before sealing",
+		}
+		return
+	}
+
+	secret := make([]byte, secretLen)
+	_, err = rand.Read(secret)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "breakglass: Failed to read random"),
+		}
+		return
+	}
+
+	parts, err = splitSecret(secret, a.Shares, a.Threshold)
+	if err != nil {
+		return
+	}
+
+	a.SecretHash = hashSecret(secret)
+	a.Sealed = true
+	a.SealedAt = time.Now()
+	a.FailedAttempts = 0
+
+	err = a.CommitFields(db, set.NewSet(
+		"secret_hash",
+		"sealed",
+		"sealed_at",
+		"failed_attempts",
+	))
+	if err != nil {
+		return
+	}
+
+	err = a.disableUser(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Account) failed(db *database.Database) (err error) {
+	a.FailedAttempts += 1
+
+	err = a.CommitFields(db, set.NewSet("failed_attempts"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Account) Unlock(db *database.Database, parts []string) (
+	usr *user.User, errData *errortypes.ErrorData, err error) {
+
+	if !a.Sealed || a.SecretHash == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_unsealed",
+			Message: "Break-glass account has not been sealed",
+		}
+		return
+	}
+
+	if a.Unlocked {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_unlocked",
+			Message: "Break-glass account is already unlocked",
+		}
+		return
+	}
+
+	if len(parts) < a.Threshold {
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_shares_insufficient",
+			Message: "Not enough shares provided to unlock account",
+		}
+		return
+	}
+
+	secret, e := combineShares(parts)
+	if e != nil || subtle.ConstantTimeCompare(
+		[]byte(hashSecret(secret)), []byte(a.SecretHash)) != 1 {
+
+		err = a.failed(db)
+		if err != nil {
+			return
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_shares_invalid",
+			Message: "Break-glass account shares are not valid",
+		}
+		return
+	}
+
+	usr, err = user.Get(db, a.UserId)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	expires := now.Add(time.Duration(a.Duration) * time.Minute)
+
+	coll := db.BreakGlass()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":         a.Id,
+		"unlocked":    false,
+		"secret_hash": a.SecretHash,
+	}, &bson.M{
+		"$set": &bson.M{
+			"unlocked":        true,
+			"unlocked_at":     now,
+			"expires":         expires,
+			"failed_attempts": 0,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount == 0 {
+		usr = nil
+		errData = &errortypes.ErrorData{
+			Error:   "breakglass_unlocked",
+			Message: "Break-glass account is already unlocked",
+		}
+		return
+	}
+
+	a.Unlocked = true
+	a.UnlockedAt = now
+	a.Expires = expires
+	a.FailedAttempts = 0
+
+	usr.Disabled = false
+	usr.ActiveUntil = expires
+
+	err = usr.CommitFields(db, set.NewSet("disabled", "active_until"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Account) Lock(db *database.Database) (err error) {
+	a.Unlocked = false
+	a.Sealed = false
+	a.SecretHash = ""
+	a.Expires = time.Time{}
+
+	err = a.CommitFields(db, set.NewSet(
+		"unlocked",
+		"sealed",
+		"secret_hash",
+		"expires",
+	))
+	if err != nil {
+		return
+	}
+
+	err = a.disableUser(db)
+	if err != nil {
+		return
+	}
+
+	err = session.RemoveAll(db, a.UserId)
+	if err != nil {
+		return
+	}
+
+	err = revocation.RevokeUser(db, a.UserId, "Break-glass account locked")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Account) Commit(db *database.Database) (err error) {
+	coll := db.BreakGlass()
+
+	err = coll.Commit(a.Id, a)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Account) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.BreakGlass()
+
+	err = coll.CommitFields(a.Id, a, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Account) Insert(db *database.Database) (err error) {
+	coll := db.BreakGlass()
+
+	if !a.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("breakglass: Account already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, a)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,42 @@
+package breakglass
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/ssh"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+func (a *Account) IssueCertificate(db *database.Database, usr *user.User,
+	agnt *agent.Agent, pubKey string) (cert *ssh.Certificate, err error) {
+
+	pubKey = strings.TrimSpace(pubKey)
+	if pubKey == "" || len(a.Authorities) == 0 {
+		return
+	}
+
+	authrs, err := authority.GetMulti(db, a.Authorities)
+	if err != nil {
+		return
+	}
+
+	cert, err = ssh.NewCertificate(db, authrs, usr, agnt, pubKey, a.Duration)
+	if err != nil {
+		return
+	}
+
+	if len(cert.Certificates) == 0 {
+		cert = nil
+		return
+	}
+
+	err = cert.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,17 @@
+package breakglass
+
+import (
+	"time"
+)
+
+const (
+	MaxShares       = 10
+	DefaultDuration = 60
+	MaxDuration     = 480
+	MaxAttempts     = 5
+
+	secretLen      = 32
+	sourceDelayMin = 30 * time.Second
+	sourceDelayMax = 1 * time.Hour
+	sourceReset    = 24 * time.Hour
+)
@@ -0,0 +1,80 @@
+package breakglass
+
+import (
+	"sync"
+	"time"
+)
+
+type sourceAttempts struct {
+	count     int
+	blocked   time.Time
+	timestamp time.Time
+}
+
+var (
+	sources     = map[string]*sourceAttempts{}
+	sourcesLock = sync.Mutex{}
+)
+
+func pruneSources() {
+	for source, attempts := range sources {
+		if time.Since(attempts.timestamp) > sourceReset &&
+			time.Now().After(attempts.blocked) {
+
+			delete(sources, source)
+		}
+	}
+}
+
+func SourceBlocked(source string) (wait time.Duration) {
+	sourcesLock.Lock()
+	defer sourcesLock.Unlock()
+
+	pruneSources()
+
+	attempts := sources[source]
+	if attempts == nil {
+		return
+	}
+
+	wait = time.Until(attempts.blocked)
+	if wait < 0 {
+		wait = 0
+	}
+
+	return
+}
+
+func SourceFailed(source string) {
+	sourcesLock.Lock()
+	defer sourcesLock.Unlock()
+
+	attempts := sources[source]
+	if attempts == nil {
+		attempts = &sourceAttempts{}
+		sources[source] = attempts
+	}
+
+	attempts.count += 1
+	attempts.timestamp = time.Now()
+
+	if attempts.count < MaxAttempts {
+		return
+	}
+
+	delay := sourceDelayMin
+	for i := MaxAttempts; i < attempts.count && delay < sourceDelayMax; i++ {
+		delay *= 2
+	}
+	if delay > sourceDelayMax {
+		delay = sourceDelayMax
+	}
+
+	attempts.blocked = time.Now().Add(delay)
+}
+
+func SourceClear(source string) {
+	sourcesLock.Lock()
+	delete(sources, source)
+	sourcesLock.Unlock()
+}
@@ -0,0 +1,145 @@
+package breakglass
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+var (
+	expTable [510]byte
+	logTable [256]byte
+)
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
+
+func evalPolynomial(coeffs []byte, x byte) (y byte) {
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return
+}
+
+func splitSecret(secret []byte, shares, threshold int) (
+	parts []string, err error) {
+
+	if threshold < 2 || shares < threshold || shares > 255 {
+		err = &errortypes.ParseError{
+			errors.New("breakglass: Invalid share parameters"),
+		}
+		return
+	}
+
+	values := make([][]byte, shares)
+	for i := range values {
+		values[i] = make([]byte, len(secret)+1)
+		values[i][0] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, threshold)
+	for i, b := range secret {
+		coeffs[0] = b
+		_, err = rand.Read(coeffs[1:])
+		if err != nil {
+			err = &errortypes.UnknownError{
+				errors.Wrap(err, "breakglass: Failed to read random"),
+			}
+			return
+		}
+
+		for _, value := range values {
+			value[i+1] = evalPolynomial(coeffs, value[0])
+		}
+	}
+
+	parts = []string{}
+	for _, value := range values {
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(value))
+	}
+
+	return
+}
+
+func combineShares(parts []string) (secret []byte, err error) {
+	values := [][]byte{}
+	seen := map[byte]bool{}
+	size := 0
+
+	for _, part := range parts {
+		value, e := base64.RawURLEncoding.DecodeString(part)
+		if e != nil || len(value) < 2 {
+			err = &errortypes.ParseError{
+				errors.New("breakglass: Invalid share encoding"),
+			}
+			return
+		}
+
+		if size == 0 {
+			size = len(value)
+		}
+
+		if len(value) != size || value[0] == 0 || seen[value[0]] {
+			err = &errortypes.ParseError{
+				errors.New("breakglass: Invalid share"),
+			}
+			return
+		}
+		seen[value[0]] = true
+
+		values = append(values, value)
+	}
+
+	if len(values) < 2 {
+		err = &errortypes.ParseError{
+			errors.New("breakglass: Not enough shares"),
+		}
+		return
+	}
+
+	secret = make([]byte, size-1)
+	for i := range secret {
+		var result byte
+		for j, vj := range values {
+			basis := byte(1)
+			for k, vk := range values {
+				if j == k {
+					continue
+				}
+				basis = gfMul(basis, gfDiv(vk[0], vk[0]^vj[0]))
+			}
+			result ^= gfMul(vj[i+1], basis)
+		}
+		secret[i] = result
+	}
+
+	return
+}
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		expTable[i+255] = x
+		logTable[x] = byte(i)
+
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x = x2 ^ x
+	}
+}
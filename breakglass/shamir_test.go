@@ -0,0 +1,156 @@
+package breakglass
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		shares    int
+		threshold int
+		use       []int
+	}{
+		{2, 2, []int{0, 1}},
+		{3, 2, []int{2, 0}},
+		{5, 3, []int{0, 1, 2}},
+		{5, 3, []int{4, 2, 1}},
+		{5, 3, []int{0, 1, 2, 3, 4}},
+		{10, 7, []int{9, 8, 7, 6, 5, 4, 3}},
+		{255, 2, []int{0, 254}},
+	}
+
+	for _, test := range tests {
+		parts, err := splitSecret(secret, test.shares, test.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(parts) != test.shares {
+			t.Errorf("Wrong share count %d/%d: %d",
+				test.shares, test.threshold, len(parts))
+			continue
+		}
+
+		selected := []string{}
+		for _, i := range test.use {
+			selected = append(selected, parts[i])
+		}
+
+		combined, err := combineShares(selected)
+		if err != nil {
+			t.Errorf("Combine failed %d/%d: %s",
+				test.shares, test.threshold, err)
+			continue
+		}
+
+		if !bytes.Equal(combined, secret) {
+			t.Errorf("Secret mismatch %d/%d with shares %v",
+				test.shares, test.threshold, test.use)
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	tests := []struct {
+		shares    int
+		threshold int
+	}{
+		{1, 1},
+		{3, 1},
+		{2, 3},
+		{256, 2},
+	}
+
+	for _, test := range tests {
+		_, err := splitSecret([]byte("secret"), test.shares, test.threshold)
+		if err == nil {
+			t.Errorf("Expected error for %d/%d",
+				test.shares, test.threshold)
+		}
+	}
+}
+
+func TestCombineBelowThreshold(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		shares    int
+		threshold int
+	}{
+		{3, 3},
+		{5, 3},
+		{5, 4},
+		{10, 7},
+	}
+
+	for _, test := range tests {
+		parts, err := splitSecret(secret, test.shares, test.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		combined, err := combineShares(parts[:test.threshold-1])
+		if err != nil {
+			t.Errorf("Combine failed %d/%d: %s",
+				test.shares, test.threshold, err)
+			continue
+		}
+
+		if bytes.Equal(combined, secret) {
+			t.Errorf("Secret recovered below threshold %d/%d",
+				test.shares, test.threshold)
+		}
+	}
+
+	parts, err := splitSecret(secret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = combineShares(parts[:1])
+	if err == nil {
+		t.Errorf("Expected error for single share")
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	parts, err := splitSecret([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zero, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	zero[0] = 0
+
+	index, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	index[0] = 1
+
+	short, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	short = short[:len(short)-1]
+
+	tests := []struct {
+		name  string
+		parts []string
+	}{
+		{"duplicate", []string{parts[0], parts[0]}},
+		{"duplicate_index", []string{
+			parts[0], base64.RawURLEncoding.EncodeToString(index)}},
+		{"zero_index", []string{
+			parts[0], base64.RawURLEncoding.EncodeToString(zero)}},
+		{"length", []string{
+			parts[0], base64.RawURLEncoding.EncodeToString(short)}},
+		{"encoding", []string{parts[0], "!!!"}},
+		{"empty", []string{}},
+	}
+
+	for _, test := range tests {
+		_, err := combineShares(test.parts)
+		if err == nil {
+			t.Errorf("Expected error for %s shares", test.name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package breakglass
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/database"
+)
+
+func Get(db *database.Database, accountId primitive.ObjectID) (
+	acct *Account, err error) {
+
+	coll := db.BreakGlass()
+	acct = &Account{}
+
+	err = coll.FindOneId(accountId, acct)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetName(db *database.Database, name string) (
+	acct *Account, err error) {
+
+	coll := db.BreakGlass()
+	acct = &Account{}
+
+	err = coll.FindOne(db, &bson.M{
+		"name": name,
+	}).Decode(acct)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database) (accounts []*Account, err error) {
+	coll := db.BreakGlass()
+	accounts = []*Account{}
+
+	cursor, err := coll.Find(db, &bson.M{}, &options.FindOptions{
+		Sort: &bson.D{
+			{"name", 1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		acct := &Account{}
+		err = cursor.Decode(acct)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		accounts = append(accounts, acct)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetExpired(db *database.Database) (accounts []*Account, err error) {
+	coll := db.BreakGlass()
+	accounts = []*Account{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"unlocked": true,
+		"expires": &bson.M{
+			"$lte": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		acct := &Account{}
+		err = cursor.Decode(acct)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		accounts = append(accounts, acct)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, accountId primitive.ObjectID) (
+	err error) {
+
+	coll := db.BreakGlass()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": accountId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,393 @@
+package challenge
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/audit"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/ssh"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+type Approval struct {
+	Id       primitive.ObjectID `json:"id"`
+	UserId   primitive.ObjectID `json:"user_id"`
+	Username string             `json:"username"`
+	PubKey   string             `json:"pub_key"`
+	Ttl      int                `json:"ttl"`
+	Agent    *agent.Agent       `json:"agent"`
+	Expires  time.Time          `json:"expires"`
+}
+
+func (c *Challenge) requestApproval(db *database.Database, usr *user.User,
+	agnt *agent.Agent, expire int) (err error) {
+
+	c.State = ssh.Pending
+	c.Timestamp = time.Time{}
+	c.UserId = usr.Id
+	c.Agent = agnt
+	c.ApprovalId = primitive.NewObjectID()
+	c.ApprovalExpires = time.Now().Add(time.Duration(expire) * time.Minute)
+
+	coll := db.SshChallenges()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":   c.Id,
+		"state": "",
+	}, &bson.M{
+		"$set": c,
+		"$unset": &bson.M{
+			"timestamp": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (c *Challenge) expireApproval(db *database.Database) (err error) {
+	c.State = ssh.Denied
+	c.CertificateId = primitive.NilObjectID
+
+	coll := db.SshChallenges()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":   c.Id,
+		"state": ssh.Pending,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state": c.State,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (c *Challenge) Refresh(db *database.Database) (err error) {
+	if c.State != ssh.Pending {
+		return
+	}
+
+	if time.Now().After(c.ApprovalExpires) {
+		err = c.expireApproval(db)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Challenge) canApprove(approver *user.User,
+	authrs []*authority.Authority) bool {
+
+	if approver.Id == c.UserId {
+		return false
+	}
+
+	required := false
+	for _, authr := range authrs {
+		if !authr.ApprovalRequired {
+			continue
+		}
+		required = true
+
+		if !authr.ApproverHasAccess(approver) {
+			return false
+		}
+	}
+
+	return required
+}
+
+func (c *Challenge) validateApprover(db *database.Database,
+	approver *user.User) (usr *user.User, authrs []*authority.Authority,
+	errData *errortypes.ErrorData, err error) {
+
+	if c.State != ssh.Pending {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_unavailable",
+			Message: "Certificate request is not waiting for approval",
+		}
+		return
+	}
+
+	if time.Now().After(c.ApprovalExpires) {
+		err = c.expireApproval(db)
+		if err != nil {
+			return
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "approval_expired",
+			Message: "Certificate request approval window has expired",
+		}
+		return
+	}
+
+	if approver.Id == c.UserId {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_self",
+			Message: "Cannot approve own certificate request",
+		}
+		return
+	}
+
+	usr, err = user.Get(db, c.UserId)
+	if err != nil {
+		return
+	}
+
+	_, authrs, err = getAuthorities(db, usr)
+	if err != nil {
+		return
+	}
+
+	if !c.canApprove(approver, authrs) {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_unauthorized",
+			Message: "Not authorized to approve certificate request",
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Challenge) ApprovalApprove(db *database.Database,
+	approver *user.User) (errData *errortypes.ErrorData, err error) {
+
+	usr, authrs, errData, err := c.validateApprover(db, approver)
+	if err != nil || errData != nil {
+		return
+	}
+
+	if usr.Disabled {
+		err = c.expireApproval(db)
+		if err != nil {
+			return
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "approval_user_disabled",
+			Message: "Requesting user is disabled",
+		}
+		return
+	}
+
+	c.ApproverId = approver.Id
+
+	claimed, err := c.issue(db, usr, c.Agent, authrs, ssh.Pending)
+	if err != nil {
+		return
+	}
+
+	if !claimed {
+		errData = &errortypes.ErrorData{
+			Error:   "approval_unavailable",
+			Message: "Certificate request is not waiting for approval",
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Challenge) ApprovalDeny(db *database.Database,
+	approver *user.User) (errData *errortypes.ErrorData, err error) {
+
+	_, _, errData, err = c.validateApprover(db, approver)
+	if err != nil || errData != nil {
+		return
+	}
+
+	c.State = ssh.Denied
+	c.ApproverId = approver.Id
+	c.CertificateId = primitive.NilObjectID
+
+	coll := db.SshChallenges()
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":   c.Id,
+		"state": ssh.Pending,
+	}, &bson.M{
+		"$set": c,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetApproval(db *database.Database, approvalId primitive.ObjectID) (
+	chal *Challenge, err error) {
+
+	coll := db.SshChallenges()
+	chal = &Challenge{}
+
+	err = coll.FindOne(db, &bson.M{
+		"approval_id": approvalId,
+	}).Decode(chal)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Approve(db *database.Database, r *http.Request,
+	approvalId primitive.ObjectID, approver *user.User) (
+	errData *errortypes.ErrorData, err error) {
+
+	chal, err := GetApproval(db, approvalId)
+	if err != nil {
+		return
+	}
+
+	errData, err = chal.ApprovalApprove(db, approver)
+	if err != nil || errData != nil {
+		return
+	}
+
+	err = audit.New(
+		db,
+		r,
+		chal.UserId,
+		audit.SshApprovalApprove,
+		audit.Fields{
+			"ssh_key":       chal.PubKey,
+			"ttl_requested": chal.Ttl,
+			"lifetime":      chal.Lifetime,
+			"approver_id":   approver.Id,
+			"approver":      approver.Username,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	event.Publish(db, "ssh_challenge", chal.Id)
+
+	return
+}
+
+func Deny(db *database.Database, r *http.Request,
+	approvalId primitive.ObjectID, approver *user.User) (
+	errData *errortypes.ErrorData, err error) {
+
+	chal, err := GetApproval(db, approvalId)
+	if err != nil {
+		return
+	}
+
+	errData, err = chal.ApprovalDeny(db, approver)
+	if err != nil || errData != nil {
+		return
+	}
+
+	err = audit.New(
+		db,
+		r,
+		chal.UserId,
+		audit.SshApprovalDeny,
+		audit.Fields{
+			"ssh_key":     chal.PubKey,
+			"approver_id": approver.Id,
+			"approver":    approver.Username,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	event.Publish(db, "ssh_challenge", chal.Id)
+
+	return
+}
+
+func GetApprovals(db *database.Database, approver *user.User) (
+	approvals []*Approval, err error) {
+
+	coll := db.SshChallenges()
+	approvals = []*Approval{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"state": ssh.Pending,
+		"approval_expires": &bson.M{
+			"$gt": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		chal := &Challenge{}
+		err = cursor.Decode(chal)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		if chal.UserId == approver.Id {
+			continue
+		}
+
+		usr, e := user.Get(db, chal.UserId)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				continue
+			}
+			err = e
+			return
+		}
+
+		_, authrs, e := getAuthorities(db, usr)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !chal.canApprove(approver, authrs) {
+			continue
+		}
+
+		approvals = append(approvals, &Approval{
+			Id:       chal.ApprovalId,
+			UserId:   usr.Id,
+			Username: usr.Username,
+			PubKey:   chal.PubKey,
+			Ttl:      chal.Ttl,
+			Agent:    chal.Agent,
+			Expires:  chal.ApprovalExpires,
+		})
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson"
@@ -22,24 +23,30 @@ import (
 )
 
 type Challenge struct {
-	Id            string             `bson:"_id"`
-	CertificateId primitive.ObjectID `bson:"certificate_id,omitempty"`
-	Timestamp     time.Time          `bson:"timestamp"`
-	State         string             `bson:"state"`
-	PubKey        string             `bson:"pub_key"`
+	Id              string             `bson:"_id"`
+	CertificateId   primitive.ObjectID `bson:"certificate_id,omitempty"`
+	Timestamp       time.Time          `bson:"timestamp,omitempty"`
+	State           string             `bson:"state"`
+	PubKey          string             `bson:"pub_key"`
+	Ttl             int                `bson:"ttl"`
+	Lifetime        int                `bson:"lifetime"`
+	UserId          primitive.ObjectID `bson:"user_id,omitempty"`
+	Agent           *agent.Agent       `bson:"agent,omitempty"`
+	ApprovalId      primitive.ObjectID `bson:"approval_id,omitempty"`
+	ApprovalExpires time.Time          `bson:"approval_expires,omitempty"`
+	ApproverId      primitive.ObjectID `bson:"approver_id,omitempty"`
 }
 
-func (c *Challenge) Approve(db *database.Database, usr *user.User,
-	r *http.Request, deviceSec, secondary bool) (deviceAuth bool,
-	secProvider primitive.ObjectID, err error, errData *errortypes.ErrorData) {
+func getAuthorities(db *database.Database, usr *user.User) (
+	authrIds []primitive.ObjectID, authrs []*authority.Authority, err error) {
 
 	allAuthrs, err := authority.GetAll(db)
 	if err != nil {
 		return
 	}
 
-	authrIds := []primitive.ObjectID{}
-	authrs := []*authority.Authority{}
+	authrIds = []primitive.ObjectID{}
+	authrs = []*authority.Authority{}
 	for _, authr := range allAuthrs {
 		if authr.UserHasAccess(usr) {
 			authrIds = append(authrIds, authr.Id)
@@ -47,13 +54,109 @@ func (c *Challenge) Approve(db *database.Database, usr *user.User,
 		}
 	}
 
+	return
+}
+
+func (c *Challenge) issue(db *database.Database, usr *user.User,
+	agnt *agent.Agent, authrs []*authority.Authority, curState string) (
+	claimed bool, err error) {
+
+	coll := db.SshChallenges()
+
+	resp, err := coll.UpdateOne(db, &bson.M{
+		"_id":   c.Id,
+		"state": curState,
+	}, &bson.M{
+		"$set": &bson.M{
+			"state": ssh.Issuing,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount == 0 {
+		return
+	}
+	claimed = true
+	c.State = ssh.Issuing
+
+	cert, err := ssh.NewCertificate(db, authrs, usr, agnt, c.PubKey, c.Ttl)
+	if err != nil {
+		c.State = ssh.Unavailable
+		c.CertificateId = primitive.NilObjectID
+
+		_, e := coll.UpdateOne(db, &bson.M{
+			"_id":   c.Id,
+			"state": ssh.Issuing,
+		}, &bson.M{
+			"$set": &bson.M{
+				"state": c.State,
+			},
+		})
+		if e != nil {
+			e = database.ParseError(e)
+			logrus.WithFields(logrus.Fields{
+				"challenge_id": c.Id,
+				"error":        e,
+			}).Error("challenge: Failed to release challenge state")
+		}
+		return
+	}
+	cert.ApproverId = c.ApproverId
+
+	for _, info := range cert.CertificatesInfo {
+		if info.Lifetime > c.Lifetime {
+			c.Lifetime = info.Lifetime
+		}
+	}
+
+	if len(cert.Certificates) == 0 {
+		c.State = ssh.Unavailable
+		c.CertificateId = primitive.NilObjectID
+	} else {
+		err = cert.Insert(db)
+		if err != nil {
+			return
+		}
+
+		c.State = ssh.Approved
+		c.CertificateId = cert.Id
+	}
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"_id":   c.Id,
+		"state": ssh.Issuing,
+	}, &bson.M{
+		"$set": c,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (c *Challenge) Approve(db *database.Database, usr *user.User,
+	r *http.Request, deviceSec, secondary bool) (deviceAuth bool,
+	secProvider primitive.ObjectID, err error, errData *errortypes.ErrorData) {
+
+	authrIds, authrs, err := getAuthorities(db, usr)
+	if err != nil {
+		return
+	}
+
 	policies, err := policy.GetAuthoritiesRoles(db, authrIds, usr.Roles)
 	if err != nil {
 		return
 	}
 
 	for _, polcy := range policies {
-		errData, err = polcy.ValidateUser(db, usr, r)
+		_, errData, err = polcy.ValidateUser(db, usr, r, &policy.Target{
+			AuthorityIds: authrIds,
+		})
 		if err != nil || errData != nil {
 			err = c.Deny(db, usr)
 			if err != nil {
@@ -172,34 +275,36 @@ func (c *Challenge) Approve(db *database.Database, usr *user.User,
 		return
 	}
 
-	cert, err := ssh.NewCertificate(db, authrs, usr, agnt, c.PubKey)
-	if err != nil {
-		return
+	approvalExpire := 0
+	for _, authr := range authrs {
+		if !authr.ApprovalRequired {
+			continue
+		}
+
+		expire := authr.GetApprovalExpire()
+		if approvalExpire == 0 || expire < approvalExpire {
+			approvalExpire = expire
+		}
 	}
 
-	if len(cert.Certificates) == 0 {
-		c.State = ssh.Unavailable
-		c.CertificateId = primitive.NilObjectID
-	} else {
-		err = cert.Insert(db)
+	if approvalExpire != 0 {
+		err = c.requestApproval(db, usr, agnt, approvalExpire)
 		if err != nil {
 			return
 		}
-
-		c.State = ssh.Approved
-		c.CertificateId = cert.Id
+		return
 	}
 
-	coll := db.SshChallenges()
-
-	_, err = coll.UpdateOne(db, &bson.M{
-		"_id":   c.Id,
-		"state": "",
-	}, &bson.M{
-		"$set": c,
-	})
+	claimed, err := c.issue(db, usr, agnt, authrs, "")
 	if err != nil {
-		err = database.ParseError(err)
+		return
+	}
+
+	if !claimed {
+		errData = &errortypes.ErrorData{
+			Error:   "challenge_unavailable",
+			Message: "Certificate request has already been processed",
+		}
 		return
 	}
 
@@ -269,7 +374,7 @@ func (c *Challenge) Insert(db *database.Database) (err error) {
 	return
 }
 
-func NewChallenge(db *database.Database, pubKey string) (
+func NewChallenge(db *database.Database, pubKey string, ttl int) (
 	chal *Challenge, err error) {
 
 	pubKey = strings.TrimSpace(pubKey)
@@ -290,6 +395,7 @@ func NewChallenge(db *database.Database, pubKey string) (
 		Id:        token,
 		Timestamp: time.Now(),
 		PubKey:    pubKey,
+		Ttl:       ttl,
 	}
 
 	err = chal.Insert(db)
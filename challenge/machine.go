@@ -0,0 +1,119 @@
+package challenge
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/node"
+	"github.com/pritunl/pritunl-zero/policy"
+	"github.com/pritunl/pritunl-zero/settings"
+	"github.com/pritunl/pritunl-zero/ssh"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+func IssueMachine(db *database.Database, usr *user.User, r *http.Request,
+	pubKey string, ttl int) (cert *ssh.Certificate,
+	errData *errortypes.ErrorData, err error) {
+
+	if usr.Type != user.Api || usr.Machine == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "machine_unavailable",
+			Message: "User is not configured as a machine identity",
+		}
+		return
+	}
+
+	if !usr.Machine.NetworkAllowed(node.Self.GetRemoteAddr(r)) {
+		errData = &errortypes.ErrorData{
+			Error:   "machine_network_denied",
+			Message: "Request source network is not allowed",
+		}
+		return
+	}
+
+	pubKey = strings.TrimSpace(pubKey)
+	if pubKey == "" || len(pubKey) > settings.System.SshPubKeyLen {
+		errData = &errortypes.ErrorData{
+			Error:   "public_key_invalid",
+			Message: "Public key is invalid",
+		}
+		return
+	}
+
+	machineAuthrs, err := authority.GetMulti(db, usr.Machine.Authorities)
+	if err != nil {
+		return
+	}
+
+	authrIds := []primitive.ObjectID{}
+	authrs := []*authority.Authority{}
+	for _, authr := range machineAuthrs {
+		if authr.ApprovalRequired || !authr.UserHasAccess(usr) {
+			continue
+		}
+
+		authrIds = append(authrIds, authr.Id)
+		authrs = append(authrs, authr)
+	}
+
+	policies, err := policy.GetAuthoritiesRoles(db, authrIds, usr.Roles)
+	if err != nil {
+		return
+	}
+
+	for _, polcy := range policies {
+		_, errData, err = polcy.ValidateUser(db, usr, r, &policy.Target{
+			AuthorityIds: authrIds,
+		})
+		if err != nil || errData != nil {
+			return
+		}
+
+		if polcy.Disabled {
+			continue
+		}
+
+		if polcy.AuthorityDeviceSecondary ||
+			!polcy.AuthoritySecondary.IsZero() ||
+			polcy.AuthorityRequireSmartCard {
+
+			errData = &errortypes.ErrorData{
+				Error: "machine_secondary_unsupported",
+				Message: "Authority policy requires interactive " +
+					"authentication",
+			}
+			return
+		}
+	}
+
+	agnt, err := agent.Parse(db, r)
+	if err != nil {
+		return
+	}
+
+	cert, err = ssh.NewCertificate(db, authrs, usr, agnt, pubKey, ttl)
+	if err != nil {
+		return
+	}
+
+	if len(cert.Certificates) == 0 {
+		cert = nil
+		errData = &errortypes.ErrorData{
+			Error:   "certificate_unavailable",
+			Message: "No certificates are available",
+		}
+		return
+	}
+
+	err = cert.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
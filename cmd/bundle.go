@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	bundleSaltLen = 32
+	bundleKeyLen  = 32
+	bundleScryptN = 32768
+	bundleScryptR = 8
+	bundleScryptP = 1
+)
+
+func bundleCipher(passphrase string, salt []byte) (
+	gcm cipher.AEAD, err error) {
+
+	key, err := scrypt.Key([]byte(passphrase), salt,
+		bundleScryptN, bundleScryptR, bundleScryptP, bundleKeyLen)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to derive key"),
+		}
+		return
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to create cipher"),
+		}
+		return
+	}
+
+	gcm, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to create cipher"),
+		}
+		return
+	}
+
+	return
+}
+
+func encryptAuthorities(passphrase string, authrs []*exportAuthority,
+	data *exportData) (err error) {
+
+	plainData, err := json.Marshal(authrs)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to marshal authorities"),
+		}
+		return
+	}
+
+	salt := make([]byte, bundleSaltLen)
+	_, err = rand.Read(salt)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "cmd.bundle: Failed to read random"),
+		}
+		return
+	}
+
+	gcm, err := bundleCipher(passphrase, salt)
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "cmd.bundle: Failed to read random"),
+		}
+		return
+	}
+
+	data.Salt = base64.StdEncoding.EncodeToString(salt)
+	data.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	data.Authorities = base64.StdEncoding.EncodeToString(
+		gcm.Seal(nil, nonce, plainData, nil))
+
+	return
+}
+
+func decryptAuthorities(passphrase string, data *exportData) (
+	authrs []*exportAuthority, err error) {
+
+	salt, err := base64.StdEncoding.DecodeString(data.Salt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to decode salt"),
+		}
+		return
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(data.Nonce)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to decode nonce"),
+		}
+		return
+	}
+
+	cipherData, err := base64.StdEncoding.DecodeString(data.Authorities)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to decode authorities"),
+		}
+		return
+	}
+
+	gcm, err := bundleCipher(passphrase, salt)
+	if err != nil {
+		return
+	}
+
+	if len(nonce) != gcm.NonceSize() {
+		err = &errortypes.ParseError{
+			errors.New("cmd.bundle: Invalid nonce length"),
+		}
+		return
+	}
+
+	plainData, err := gcm.Open(nil, nonce, cipherData, nil)
+	if err != nil {
+		err = &errortypes.AuthenticationError{
+			errors.Wrap(err, "cmd.bundle: Failed to decrypt authorities"),
+		}
+		return
+	}
+
+	authrs = []*exportAuthority{}
+	err = json.Unmarshal(plainData, &authrs)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.bundle: Failed to unmarshal authorities"),
+		}
+		return
+	}
+
+	return
+}
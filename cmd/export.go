@@ -13,8 +13,16 @@ import (
 	"syscall"
 )
 
+type exportAuthority struct {
+	Authority  *authority.Authority `json:"authority"`
+	PrivateKey string               `json:"private_key"`
+}
+
 type exportData struct {
-	Keys []string `json:"keys"`
+	Keys        []string `json:"keys"`
+	Salt        string   `json:"salt"`
+	Nonce       string   `json:"nonce"`
+	Authorities string   `json:"authorities"`
 }
 
 func ExportSsh() (err error) {
@@ -47,21 +55,35 @@ func ExportSsh() (err error) {
 	}
 
 	keys := []string{}
+	exportAuthrs := []*exportAuthority{}
 
 	for _, authr := range authrs {
-		key, e := authr.Export(pass)
-		if e != nil {
-			err = e
-			return
+		key := ""
+
+		if authr.PrivateKey != "" {
+			key, err = authr.Export(pass)
+			if err != nil {
+				return
+			}
+
+			keys = append(keys, key)
 		}
 
-		keys = append(keys, key)
+		exportAuthrs = append(exportAuthrs, &exportAuthority{
+			Authority:  authr,
+			PrivateKey: key,
+		})
 	}
 
 	data := &exportData{
 		Keys: keys,
 	}
 
+	err = encryptAuthorities(pass, exportAuthrs, data)
+	if err != nil {
+		return
+	}
+
 	marhData, err := json.Marshal(data)
 	if err != nil {
 		err = &errortypes.ParseError{
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-zero/hostagent"
+)
+
+func HostAgent() (err error) {
+	conf, err := hostagent.LoadConfig(flag.Arg(1))
+	if err != nil {
+		return
+	}
+
+	agnt, err := hostagent.New(conf)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		sig := make(chan os.Signal, 2)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		logrus.Info("cmd.hostagent: Shutting down")
+		agnt.Stop()
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"server":   conf.Server,
+		"hostname": conf.Hostname,
+		"port":     conf.Port,
+		"https":    conf.Https,
+	}).Info("cmd.hostagent: Starting host agent")
+
+	err = agnt.Run()
+	if err != nil {
+		return
+	}
+
+	return
+}
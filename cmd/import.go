@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"syscall"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/config"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/event"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func authorityChanges(cur, imp *authority.Authority) (
+	changes []string, err error) {
+
+	changes = []string{}
+
+	curData := map[string]interface{}{}
+	impData := map[string]interface{}{}
+
+	for _, item := range []struct {
+		authr *authority.Authority
+		data  *map[string]interface{}
+	}{
+		{cur, &curData},
+		{imp, &impData},
+	} {
+		marhData, e := json.Marshal(item.authr)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "cmd.import: Failed to marshal authority"),
+			}
+			return
+		}
+
+		e = json.Unmarshal(marhData, item.data)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "cmd.import: Failed to unmarshal authority"),
+			}
+			return
+		}
+	}
+
+	for key, val := range impData {
+		switch key {
+		case "hsm_status", "hsm_timestamp", "proxy_jump", "bastion_status",
+			"bastion_restarts", "bastion_error", "bastion_timestamp":
+			continue
+		}
+
+		if !reflect.DeepEqual(curData[key], val) {
+			changes = append(changes, key)
+		}
+	}
+
+	if cur.PrivateKey != imp.PrivateKey {
+		changes = append(changes, "private_key")
+	}
+
+	sort.Strings(changes)
+
+	return
+}
+
+func ImportSsh() (err error) {
+	inputPath := ""
+	dryRun := false
+
+	for _, arg := range flag.Args()[1:] {
+		if arg == "--dry-run" {
+			dryRun = true
+		} else {
+			inputPath = arg
+		}
+	}
+
+	if inputPath == "" {
+		err = &errortypes.ReadError{
+			errors.New("cmd.import: Missing import path"),
+		}
+		return
+	}
+
+	inputData, err := ioutil.ReadFile(inputPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "cmd.import: Failed to read input file"),
+		}
+		return
+	}
+
+	data := &exportData{}
+	err = json.Unmarshal(inputData, data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.import: Failed to unmarshal input file"),
+		}
+		return
+	}
+
+	if data.Authorities == "" {
+		err = &errortypes.ParseError{
+			errors.New("cmd.import: Export is missing authority data, " +
+				"export again with current version"),
+		}
+		return
+	}
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	fmt.Print("Enter encryption passphrase: ")
+	passByt, err := terminal.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "cmd.import: Failed to read passphrase"),
+		}
+		return
+	}
+	pass := string(passByt)
+	fmt.Println("")
+
+	exportAuthrs, err := decryptAuthorities(pass, data)
+	if err != nil {
+		return
+	}
+
+	changed := false
+
+	for _, exportAuthr := range exportAuthrs {
+		authr := exportAuthr.Authority
+		if authr == nil || authr.Id.IsZero() {
+			continue
+		}
+
+		if exportAuthr.PrivateKey != "" {
+			err = authr.Import(pass, exportAuthr.PrivateKey)
+			if err != nil {
+				return
+			}
+		}
+
+		curAuthr, e := authority.Get(db, authr.Id)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+			curAuthr = nil
+		}
+
+		if curAuthr == nil {
+			fmt.Printf("Create authority %s (%s)\n",
+				authr.Name, authr.Id.Hex())
+
+			if dryRun {
+				continue
+			}
+
+			if authr.Type == authority.Pkcs11 &&
+				config.Config.Pkcs11Pins[authr.Pkcs11Token] == "" {
+
+				err = &errortypes.ParseError{
+					errors.Newf("cmd.import: Authority %s requires pkcs11 "+
+						"pin for token %s, set with pkcs11-pin",
+						authr.Id.Hex(), authr.Pkcs11Token),
+				}
+				return
+			}
+
+			errData, e := authr.Validate(db)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if errData != nil {
+				err = &errortypes.ParseError{
+					errors.Newf("cmd.import: Authority %s invalid, %s",
+						authr.Id.Hex(), errData.Message),
+				}
+				return
+			}
+
+			err = authr.Upsert(db)
+			if err != nil {
+				return
+			}
+
+			changed = true
+			continue
+		}
+
+		authr.ProxyPrivateKey = curAuthr.ProxyPrivateKey
+		authr.ProxyPublicKey = curAuthr.ProxyPublicKey
+		if exportAuthr.PrivateKey == "" {
+			authr.PrivateKey = curAuthr.PrivateKey
+		}
+
+		changes, e := authorityChanges(curAuthr, authr)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if len(changes) == 0 {
+			fmt.Printf("Unchanged authority %s (%s)\n",
+				authr.Name, authr.Id.Hex())
+			continue
+		}
+
+		fmt.Printf("Update authority %s (%s)\n", authr.Name, authr.Id.Hex())
+		for _, change := range changes {
+			fmt.Printf("  %s\n", change)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		errData, e := authr.Validate(db)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData != nil {
+			err = &errortypes.ParseError{
+				errors.Newf("cmd.import: Authority %s invalid, %s",
+					authr.Id.Hex(), errData.Message),
+			}
+			return
+		}
+
+		changes, err = authorityChanges(curAuthr, authr)
+		if err != nil {
+			return
+		}
+
+		fields := set.NewSet()
+		for _, change := range changes {
+			fields.Add(change)
+		}
+
+		err = authr.CommitFields(db, fields)
+		if err != nil {
+			return
+		}
+
+		changed = true
+	}
+
+	if changed {
+		event.PublishDispatch(db, "authority.change")
+	}
+
+	if dryRun {
+		fmt.Println("Dry run, no changes were made")
+	} else {
+		fmt.Printf("Successfully imported authorities from %s\n", inputPath)
+	}
+
+	return
+}
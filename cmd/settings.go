@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"syscall"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/errors"
@@ -14,6 +15,7 @@ import (
 	"github.com/pritunl/pritunl-zero/errortypes"
 	"github.com/pritunl/pritunl-zero/settings"
 	"github.com/pritunl/pritunl-zero/user"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func Mongo() (err error) {
@@ -58,6 +60,53 @@ func ResetId() (err error) {
 	return
 }
 
+func Pkcs11Pin() (err error) {
+	token := flag.Arg(1)
+
+	if token == "" {
+		err = &errortypes.ParseError{
+			errors.New("cmd: Missing pkcs11 token label"),
+		}
+		return
+	}
+
+	err = config.Load()
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Enter PKCS#11 PIN for %s: ", token)
+	pinByt, err := terminal.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "cmd: Failed to read pin"),
+		}
+		return
+	}
+	fmt.Println("")
+
+	if config.Config.Pkcs11Pins == nil {
+		config.Config.Pkcs11Pins = map[string]string{}
+	}
+
+	if len(pinByt) == 0 {
+		delete(config.Config.Pkcs11Pins, token)
+	} else {
+		config.Config.Pkcs11Pins[token] = string(pinByt)
+	}
+
+	err = config.Save()
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"pkcs11_token": token,
+	}).Info("cmd: Set PKCS#11 PIN")
+
+	return
+}
+
 func DefaultPassword() (err error) {
 	db := database.GetDatabase()
 	defer db.Close()
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/sshlogin"
+)
+
+func SshLogin() (err error) {
+	flags := flag.NewFlagSet("ssh-login", flag.ContinueOnError)
+	keyPath := flags.String("key", "", "Path to ssh private key")
+	ttl := flags.Int("ttl", 0, "Requested certificate lifetime in minutes")
+	addAgent := flags.Bool("agent", false, "Add certificate to ssh-agent")
+	noBrowser := flags.Bool("no-browser", false, "Do not open browser")
+
+	err = flags.Parse(flag.Args()[1:])
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "cmd.sshlogin: Failed to parse arguments"),
+		}
+		return
+	}
+
+	login := &sshlogin.Login{
+		Server:    flags.Arg(0),
+		KeyPath:   *keyPath,
+		Ttl:       *ttl,
+		Agent:     *addAgent,
+		NoBrowser: *noBrowser,
+	}
+
+	err = login.Run()
+	if err != nil {
+		return
+	}
+
+	return
+}
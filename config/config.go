@@ -21,10 +21,11 @@ var (
 )
 
 type ConfigData struct {
-	path     string `json:"-"`
-	loaded   bool   `json:"-"`
-	MongoUri string `json:"mongo_uri"`
-	NodeId   string `json:"node_id"`
+	path       string            `json:"-"`
+	loaded     bool              `json:"-"`
+	MongoUri   string            `json:"mongo_uri"`
+	NodeId     string            `json:"node_id"`
+	Pkcs11Pins map[string]string `json:"pkcs11_pins,omitempty"`
 }
 
 func (c *ConfigData) Save() (err error) {
@@ -85,6 +85,11 @@ func (d *Database) Policies() (coll *Collection) {
 	return
 }
 
+func (d *Database) PolicyMonitors() (coll *Collection) {
+	coll = d.getCollection("policy_monitors")
+	return
+}
+
 func (d *Database) Devices() (coll *Collection) {
 	coll = d.getCollection("devices")
 	return
@@ -160,6 +165,31 @@ func (d *Database) SshCertificates() (coll *Collection) {
 	return
 }
 
+func (d *Database) SshSessions() (coll *Collection) {
+	coll = d.getCollection("ssh_sessions")
+	return
+}
+
+func (d *Database) SshRecordings() (coll *Collection) {
+	coll = d.getCollection("ssh_recordings")
+	return
+}
+
+func (d *Database) Hosts() (coll *Collection) {
+	coll = d.getCollection("hosts")
+	return
+}
+
+func (d *Database) BreakGlass() (coll *Collection) {
+	coll = d.getCollection("breakglass")
+	return
+}
+
+func (d *Database) SshRevocations() (coll *Collection) {
+	coll = d.getCollection("ssh_revocations")
+	return
+}
+
 func (d *Database) AcmeChallenges() (coll *Collection) {
 	coll = d.getCollection("acme_challenges")
 	return
@@ -355,6 +385,18 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.Audits(),
+		Keys: &bson.D{
+			{"y", 1},
+			{"t", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Policies(),
 		Keys: &bson.D{
@@ -386,6 +428,18 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.PolicyMonitors(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 2 * time.Hour,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.CsrfTokens(),
 		Keys: &bson.D{
@@ -457,6 +511,28 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SshRevocations(),
+		Keys: &bson.D{
+			{"authority_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshRevocations(),
+		Keys: &bson.D{
+			{"expires", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Devices(),
 		Keys: &bson.D{
@@ -512,6 +588,40 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SshChallenges(),
+		Keys: &bson.D{
+			{"approval_expires", 1},
+		},
+		Expire: 6 * time.Minute,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshChallenges(),
+		Keys: &bson.D{
+			{"approval_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshChallenges(),
+		Keys: &bson.D{
+			{"state", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.SshCertificates(),
 		Keys: &bson.D{
@@ -524,6 +634,124 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SshCertificates(),
+		Keys: &bson.D{
+			{"user_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshCertificates(),
+		Keys: &bson.D{
+			{"certificates_info.serial", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshSessions(),
+		Keys: &bson.D{
+			{"start", 1},
+		},
+		Expire: 720 * time.Hour,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshSessions(),
+		Keys: &bson.D{
+			{"user_id", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshRecordings(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 720 * time.Hour,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.SshRecordings(),
+		Keys: &bson.D{
+			{"session_id", 1},
+			{"index", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Hosts(),
+		Keys: &bson.D{
+			{"hostname", 1},
+			{"authority_id", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.Hosts(),
+		Keys: &bson.D{
+			{"expires", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.BreakGlass(),
+		Keys: &bson.D{
+			{"name", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.BreakGlass(),
+		Keys: &bson.D{
+			{"unlocked", 1},
+			{"expires", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Devices(),
 		Keys: &bson.D{
@@ -720,6 +948,21 @@ func fixData() (err error) {
 		return
 	}
 
+	coll = db.Authorities()
+	_, err = coll.UpdateMany(db, &bson.M{
+		"pkcs11_pin": &bson.M{
+			"$exists": true,
+		},
+	}, &bson.M{
+		"$unset": &bson.M{
+			"pkcs11_pin": 1,
+		},
+	})
+	if err != nil {
+		err = ParseError(err)
+		return
+	}
+
 	coll = db.SshChallenges()
 	_, err = coll.UpdateMany(db, &bson.M{
 		"certificate_id": nil,
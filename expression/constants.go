@@ -0,0 +1,32 @@
+package expression
+
+const (
+	Bool   = "bool"
+	Int    = "int"
+	String = "string"
+	List   = "list"
+
+	MaxLength = 2048
+)
+
+var Variables = map[string]string{
+	"agent.ip":               String,
+	"agent.isp":              String,
+	"agent.continent_code":   String,
+	"agent.country_code":     String,
+	"agent.region_code":      String,
+	"agent.city":             String,
+	"agent.operating_system": String,
+	"agent.browser":          String,
+	"user.id":                String,
+	"user.username":          String,
+	"user.type":              String,
+	"user.roles":             List,
+	"time.hour":              Int,
+	"time.minute":            Int,
+	"time.weekday":           Int,
+	"time.unix":              Int,
+	"device.count":           Int,
+	"service.id":             String,
+	"authority.ids":          List,
+}
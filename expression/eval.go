@@ -0,0 +1,171 @@
+package expression
+
+import (
+	"net"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+func listContains(list interface{}, val interface{}) bool {
+	switch items := list.(type) {
+	case []string:
+		for _, item := range items {
+			if item == val {
+				return true
+			}
+		}
+		break
+	case []interface{}:
+		for _, item := range items {
+			if item == val {
+				return true
+			}
+		}
+		break
+	}
+
+	return false
+}
+
+func listLen(list interface{}) int64 {
+	switch items := list.(type) {
+	case []string:
+		return int64(len(items))
+	case []interface{}:
+		return int64(len(items))
+	}
+
+	return 0
+}
+
+func inNetwork(ipStr string, networks []interface{}) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+
+	for _, networkInf := range networks {
+		_, network, err := net.ParseCIDR(networkInf.(string))
+		if err != nil {
+			continue
+		}
+
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *node) eval(vars map[string]interface{}) (
+	val interface{}, err error) {
+
+	switch n.kind {
+	case nodeLiteral:
+		val = n.value
+		return
+	case nodeVariable:
+		v, ok := vars[n.name]
+		if !ok {
+			err = &errortypes.UnknownError{
+				errors.Newf("expression: Variable '%s' unavailable", n.name),
+			}
+			return
+		}
+		val = v
+		return
+	case nodeList:
+		items := []interface{}{}
+		for _, child := range n.children {
+			items = append(items, child.value)
+		}
+		val = items
+		return
+	case nodeUnary:
+		operand, e := n.children[0].eval(vars)
+		if e != nil {
+			err = e
+			return
+		}
+		val = !operand.(bool)
+		return
+	case nodeCall:
+		args := []interface{}{}
+		for _, child := range n.children {
+			arg, e := child.eval(vars)
+			if e != nil {
+				err = e
+				return
+			}
+			args = append(args, arg)
+		}
+
+		switch n.name {
+		case "in_network":
+			val = inNetwork(args[0].(string), args[1:])
+			break
+		case "lower":
+			val = strings.ToLower(args[0].(string))
+			break
+		case "len":
+			val = listLen(args[0])
+			break
+		}
+		return
+	}
+
+	left, err := n.children[0].eval(vars)
+	if err != nil {
+		return
+	}
+
+	switch n.op {
+	case "&&":
+		if !left.(bool) {
+			val = false
+			return
+		}
+	case "||":
+		if left.(bool) {
+			val = true
+			return
+		}
+	}
+
+	right, err := n.children[1].eval(vars)
+	if err != nil {
+		return
+	}
+
+	switch n.op {
+	case "&&", "||":
+		val = right.(bool)
+		break
+	case "==":
+		val = left == right
+		break
+	case "!=":
+		val = left != right
+		break
+	case "<":
+		val = left.(int64) < right.(int64)
+		break
+	case "<=":
+		val = left.(int64) <= right.(int64)
+		break
+	case ">":
+		val = left.(int64) > right.(int64)
+		break
+	case ">=":
+		val = left.(int64) >= right.(int64)
+		break
+	case "in":
+		val = listContains(right, left)
+		break
+	}
+
+	return
+}
@@ -0,0 +1,101 @@
+package expression
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (s *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", s.Message, s.Position)
+}
+
+type Expression struct {
+	root      *node
+	variables map[string]bool
+}
+
+func (e *Expression) Uses(name string) bool {
+	return e.variables[name]
+}
+
+func (e *Expression) Eval(vars map[string]interface{}) (
+	result bool, err error) {
+
+	val, err := e.root.eval(vars)
+	if err != nil {
+		return
+	}
+
+	result, ok := val.(bool)
+	if !ok {
+		err = &errortypes.UnknownError{
+			errors.New("expression: Expression result is not bool"),
+		}
+		return
+	}
+
+	return
+}
+
+func Parse(src string) (expr *Expression, err error) {
+	if strings.TrimSpace(src) == "" {
+		err = &SyntaxError{
+			Position: 0,
+			Message:  "expression is empty",
+		}
+		return
+	}
+
+	if len(src) > MaxLength {
+		err = &SyntaxError{
+			Position: MaxLength,
+			Message:  "expression is too long",
+		}
+		return
+	}
+
+	tokens, err := tokenize(src)
+	if err != nil {
+		return
+	}
+
+	prsr := &parser{
+		tokens:    tokens,
+		variables: map[string]bool{},
+	}
+
+	root, err := prsr.parseOr()
+	if err != nil {
+		return
+	}
+
+	tok := prsr.peek()
+	if tok.kind != tokenEof {
+		err = prsr.unexpected(tok, "expected end of expression")
+		return
+	}
+
+	if root.valType != Bool {
+		err = &SyntaxError{
+			Position: 0,
+			Message: fmt.Sprintf("expression must evaluate to bool, "+
+				"found %s", root.valType),
+		}
+		return
+	}
+
+	expr = &Expression{
+		root:      root,
+		variables: prsr.variables,
+	}
+
+	return
+}
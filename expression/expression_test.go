@@ -0,0 +1,210 @@
+package expression
+
+import (
+	"strings"
+	"testing"
+)
+
+func testVariables() map[string]interface{} {
+	return map[string]interface{}{
+		"agent.ip":               "10.0.5.20",
+		"agent.isp":              "Example ISP",
+		"agent.continent_code":   "NA",
+		"agent.country_code":     "US",
+		"agent.region_code":      "CA",
+		"agent.city":             "San Francisco",
+		"agent.operating_system": "linux",
+		"agent.browser":          "chrome",
+		"user.id":                "5a1b2c3d4e5f6a7b8c9d0e1f",
+		"user.username":          "Alice",
+		"user.type":              "local",
+		"user.roles":             []string{"admin", "ops"},
+		"time.hour":              int64(14),
+		"time.minute":            int64(30),
+		"time.weekday":           int64(2),
+		"time.unix":              int64(1700000000),
+		"device.count":           int64(2),
+		"service.id":             "",
+		"authority.ids":          []string{},
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src    string
+		result bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"!false", true},
+		{"agent.country_code == 'US'", true},
+		{"agent.country_code != \"US\"", false},
+		{"agent.country_code in ['US', 'CA']", true},
+		{"agent.country_code in []", false},
+		{"time.weekday in [1, 2, 3]", true},
+		{"'admin' in user.roles", true},
+		{"'guest' in user.roles", false},
+		{"len(user.roles) == 2", true},
+		{"lower(user.username) == 'alice'", true},
+		{"time.hour >= 9 && time.hour < 17", true},
+		{"time.hour < 9 || time.hour >= 17", false},
+		{"device.count > 1 && !(agent.browser == 'firefox')", true},
+		{"in_network(agent.ip, '10.0.0.0/16')", true},
+		{"in_network(agent.ip, '192.168.0.0/16', '10.0.5.0/24')", true},
+		{"in_network(agent.ip, '192.168.0.0/16')", false},
+		{"false && service.id == ''", false},
+		{"true || device.count > 5", true},
+		{"'it\\'s' == \"it's\"", true},
+	}
+
+	for _, test := range tests {
+		expr, err := Parse(test.src)
+		if err != nil {
+			t.Errorf("Parse failed %q: %s", test.src, err)
+			continue
+		}
+
+		result, err := expr.Eval(testVariables())
+		if err != nil {
+			t.Errorf("Eval failed %q: %s", test.src, err)
+			continue
+		}
+
+		if result != test.result {
+			t.Errorf("Wrong result %q: %t", test.src, result)
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	expr, err := Parse("device.count > 1 && agent.country_code == 'US'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !expr.Uses("device.count") || !expr.Uses("agent.country_code") {
+		t.Errorf("Missing used variables")
+	}
+
+	if expr.Uses("user.id") {
+		t.Errorf("Unexpected used variable")
+	}
+}
+
+func TestEvalMissingVariable(t *testing.T) {
+	expr, err := Parse("device.count > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = expr.Eval(map[string]interface{}{})
+	if err == nil {
+		t.Errorf("Expected error for missing variable")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		position int
+		message  string
+	}{
+		{"", 0, "expression is empty"},
+		{"   ", 0, "expression is empty"},
+		{strings.Repeat("a", MaxLength+1), MaxLength,
+			"expression is too long"},
+		{"agent.ip == 'abc", 12, "unterminated string"},
+		{"agent.ip == #", 12, "unexpected character '#'"},
+		{"time.hour > 99999999999999999999", 12, "invalid number"},
+		{"agent.ip ==", 11, "unexpected end of expression"},
+		{"(true", 5, "expected ')'"},
+		{"true)", 4, "expected end of expression"},
+		{"true false", 5, "expected end of expression"},
+		{"agent.country_code in ['US' 'CA']", 28, "expected ',' or ']'"},
+		{"agent.country_code in ['US', agent.ip]", 29,
+			"list items must be string or int literals"},
+		{"unknown.var == ''", 0, "unknown variable 'unknown.var'"},
+		{"missing(agent.ip)", 0, "unknown function 'missing'"},
+		{"lower()", 0, "function 'lower' expects 1 arguments"},
+		{"lower(agent.ip, agent.isp) == ''", 0,
+			"function 'lower' expects 1 arguments"},
+		{"in_network(agent.ip)", 0,
+			"function 'in_network' expects 2 arguments"},
+		{"in_network(agent.ip, 'invalid')", 21, "invalid network 'invalid'"},
+		{"lower(agent.ip agent.isp)", 15, "expected ',' or ')'"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.src)
+		if err == nil {
+			t.Errorf("Expected error %q", test.src)
+			continue
+		}
+
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Wrong error type %q: %T", test.src, err)
+			continue
+		}
+
+		if syntaxErr.Position != test.position {
+			t.Errorf("Wrong error position %q: %d",
+				test.src, syntaxErr.Position)
+		}
+
+		if !strings.Contains(syntaxErr.Message, test.message) {
+			t.Errorf("Wrong error message %q: %s",
+				test.src, syntaxErr.Message)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		position int
+		message  string
+	}{
+		{"agent.ip", 0, "expression must evaluate to bool, found string"},
+		{"time.hour", 0, "expression must evaluate to bool, found int"},
+		{"len(user.roles)", 0, "expression must evaluate to bool, found int"},
+		{"agent.ip && true", 0, "'&&' requires bool operand, found string"},
+		{"true || time.hour", 8, "'||' requires bool operand, found int"},
+		{"!agent.ip", 1, "'!' requires bool operand, found string"},
+		{"agent.ip == 1", 9, "cannot compare string to int"},
+		{"user.roles == user.roles", 11, "cannot compare list to list"},
+		{"agent.ip > 1", 0, "'>' requires int operand, found string"},
+		{"time.hour <= 'a'", 13, "'<=' requires int operand, found string"},
+		{"true in ['a']", 0,
+			"'in' requires string or int operand, found bool"},
+		{"agent.ip in agent.isp", 12, "'in' requires list operand"},
+		{"len(agent.ip) == 1", 4, "'len' requires list operand"},
+		{"lower(time.hour) == 'a'", 6, "'lower' requires string operand"},
+		{"in_network(agent.ip, time.hour)", 21,
+			"'in_network' requires string operand"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.src)
+		if err == nil {
+			t.Errorf("Expected error %q", test.src)
+			continue
+		}
+
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Wrong error type %q: %T", test.src, err)
+			continue
+		}
+
+		if syntaxErr.Position != test.position {
+			t.Errorf("Wrong error position %q: %d",
+				test.src, syntaxErr.Position)
+		}
+
+		if !strings.Contains(syntaxErr.Message, test.message) {
+			t.Errorf("Wrong error message %q: %s",
+				test.src, syntaxErr.Message)
+		}
+	}
+}
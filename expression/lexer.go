@@ -0,0 +1,162 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	tokenEof = iota
+	tokenIdent
+	tokenString
+	tokenInt
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBracket
+	tokenRightBracket
+	tokenComma
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c == '.' || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func tokenize(src string) (tokens []*token, err error) {
+	tokens = []*token{}
+	i := 0
+
+	for i < len(src) {
+		c := src[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i += 1
+			continue
+		case c == '(':
+			tokens = append(tokens, &token{tokenLeftParen, "(", i})
+			i += 1
+			continue
+		case c == ')':
+			tokens = append(tokens, &token{tokenRightParen, ")", i})
+			i += 1
+			continue
+		case c == '[':
+			tokens = append(tokens, &token{tokenLeftBracket, "[", i})
+			i += 1
+			continue
+		case c == ']':
+			tokens = append(tokens, &token{tokenRightBracket, "]", i})
+			i += 1
+			continue
+		case c == ',':
+			tokens = append(tokens, &token{tokenComma, ",", i})
+			i += 1
+			continue
+		case c == '"' || c == '\'':
+			start := i
+			i += 1
+			value := strings.Builder{}
+			closed := false
+
+			for i < len(src) {
+				if src[i] == '\\' && i+1 < len(src) {
+					value.WriteByte(src[i+1])
+					i += 2
+					continue
+				}
+				if src[i] == c {
+					closed = true
+					i += 1
+					break
+				}
+				value.WriteByte(src[i])
+				i += 1
+			}
+
+			if !closed {
+				err = &SyntaxError{
+					Position: start,
+					Message:  "unterminated string",
+				}
+				return
+			}
+
+			tokens = append(tokens, &token{tokenString, value.String(), start})
+			continue
+		case isDigit(c):
+			start := i
+			for i < len(src) && isDigit(src[i]) {
+				i += 1
+			}
+
+			value := src[start:i]
+			_, e := strconv.ParseInt(value, 10, 64)
+			if e != nil {
+				err = &SyntaxError{
+					Position: start,
+					Message:  fmt.Sprintf("invalid number '%s'", value),
+				}
+				return
+			}
+
+			tokens = append(tokens, &token{tokenInt, value, start})
+			continue
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentChar(src[i]) {
+				i += 1
+			}
+
+			value := src[start:i]
+			if value == "in" {
+				tokens = append(tokens, &token{tokenOperator, value, start})
+			} else {
+				tokens = append(tokens, &token{tokenIdent, value, start})
+			}
+			continue
+		}
+
+		if i+1 < len(src) {
+			op := src[i : i+2]
+			switch op {
+			case "&&", "||", "==", "!=", "<=", ">=":
+				tokens = append(tokens, &token{tokenOperator, op, i})
+				i += 2
+				continue
+			}
+		}
+
+		switch c {
+		case '!', '<', '>':
+			tokens = append(tokens, &token{tokenOperator, string(c), i})
+			i += 1
+			continue
+		}
+
+		err = &SyntaxError{
+			Position: i,
+			Message:  fmt.Sprintf("unexpected character '%c'", c),
+		}
+		return
+	}
+
+	tokens = append(tokens, &token{tokenEof, "", len(src)})
+
+	return
+}
@@ -0,0 +1,482 @@
+package expression
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	nodeLiteral = iota
+	nodeVariable
+	nodeUnary
+	nodeBinary
+	nodeList
+	nodeCall
+)
+
+type node struct {
+	kind     int
+	op       string
+	name     string
+	value    interface{}
+	children []*node
+	pos      int
+	valType  string
+}
+
+type function struct {
+	args     []string
+	variadic bool
+	result   string
+}
+
+var functions = map[string]*function{
+	"in_network": {
+		args:     []string{String, String},
+		variadic: true,
+		result:   Bool,
+	},
+	"lower": {
+		args:   []string{String},
+		result: String,
+	},
+	"len": {
+		args:   []string{List},
+		result: Int,
+	},
+}
+
+type parser struct {
+	tokens    []*token
+	index     int
+	variables map[string]bool
+}
+
+func (p *parser) peek() *token {
+	return p.tokens[p.index]
+}
+
+func (p *parser) next() (tok *token) {
+	tok = p.tokens[p.index]
+	if tok.kind != tokenEof {
+		p.index += 1
+	}
+	return
+}
+
+func (p *parser) expect(kind int, value string) (tok *token, err error) {
+	tok = p.next()
+	if tok.kind != kind {
+		err = p.unexpected(tok, fmt.Sprintf("expected '%s'", value))
+		return
+	}
+	return
+}
+
+func (p *parser) unexpected(tok *token, msg string) error {
+	if tok.kind == tokenEof {
+		return &SyntaxError{
+			Position: tok.pos,
+			Message:  "unexpected end of expression, " + msg,
+		}
+	}
+
+	return &SyntaxError{
+		Position: tok.pos,
+		Message:  fmt.Sprintf("unexpected '%s', %s", tok.value, msg),
+	}
+}
+
+func (p *parser) requireType(nde *node, typ string, op string) error {
+	if nde.valType == typ {
+		return nil
+	}
+
+	return &SyntaxError{
+		Position: nde.pos,
+		Message: fmt.Sprintf("'%s' requires %s operand, found %s",
+			op, typ, nde.valType),
+	}
+}
+
+func (p *parser) parseOr() (nde *node, err error) {
+	nde, err = p.parseAnd()
+	if err != nil {
+		return
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator || tok.value != "||" {
+			return
+		}
+		p.next()
+
+		right, e := p.parseAnd()
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = p.requireType(nde, Bool, "||")
+		if err != nil {
+			return
+		}
+		err = p.requireType(right, Bool, "||")
+		if err != nil {
+			return
+		}
+
+		nde = &node{
+			kind:     nodeBinary,
+			op:       "||",
+			children: []*node{nde, right},
+			pos:      tok.pos,
+			valType:  Bool,
+		}
+	}
+}
+
+func (p *parser) parseAnd() (nde *node, err error) {
+	nde, err = p.parseUnary()
+	if err != nil {
+		return
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator || tok.value != "&&" {
+			return
+		}
+		p.next()
+
+		right, e := p.parseUnary()
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = p.requireType(nde, Bool, "&&")
+		if err != nil {
+			return
+		}
+		err = p.requireType(right, Bool, "&&")
+		if err != nil {
+			return
+		}
+
+		nde = &node{
+			kind:     nodeBinary,
+			op:       "&&",
+			children: []*node{nde, right},
+			pos:      tok.pos,
+			valType:  Bool,
+		}
+	}
+}
+
+func (p *parser) parseUnary() (nde *node, err error) {
+	tok := p.peek()
+	if tok.kind == tokenOperator && tok.value == "!" {
+		p.next()
+
+		operand, e := p.parseUnary()
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = p.requireType(operand, Bool, "!")
+		if err != nil {
+			return
+		}
+
+		nde = &node{
+			kind:     nodeUnary,
+			op:       "!",
+			children: []*node{operand},
+			pos:      tok.pos,
+			valType:  Bool,
+		}
+		return
+	}
+
+	nde, err = p.parseComparison()
+	return
+}
+
+func (p *parser) parseComparison() (nde *node, err error) {
+	nde, err = p.parsePrimary()
+	if err != nil {
+		return
+	}
+
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return
+	}
+
+	switch tok.value {
+	case "==", "!=", "<", "<=", ">", ">=", "in":
+		break
+	default:
+		return
+	}
+	p.next()
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return
+	}
+
+	switch tok.value {
+	case "==", "!=":
+		if nde.valType == List || nde.valType != right.valType {
+			err = &SyntaxError{
+				Position: tok.pos,
+				Message: fmt.Sprintf("cannot compare %s to %s",
+					nde.valType, right.valType),
+			}
+			return
+		}
+		break
+	case "<", "<=", ">", ">=":
+		err = p.requireType(nde, Int, tok.value)
+		if err != nil {
+			return
+		}
+		err = p.requireType(right, Int, tok.value)
+		if err != nil {
+			return
+		}
+		break
+	case "in":
+		if nde.valType != String && nde.valType != Int {
+			err = &SyntaxError{
+				Position: nde.pos,
+				Message: fmt.Sprintf("'in' requires string or int "+
+					"operand, found %s", nde.valType),
+			}
+			return
+		}
+		err = p.requireType(right, List, "in")
+		if err != nil {
+			return
+		}
+		break
+	}
+
+	nde = &node{
+		kind:     nodeBinary,
+		op:       tok.value,
+		children: []*node{nde, right},
+		pos:      tok.pos,
+		valType:  Bool,
+	}
+
+	return
+}
+
+func (p *parser) parseList(start *token) (nde *node, err error) {
+	nde = &node{
+		kind:     nodeList,
+		children: []*node{},
+		pos:      start.pos,
+		valType:  List,
+	}
+
+	if p.peek().kind == tokenRightBracket {
+		p.next()
+		return
+	}
+
+	for {
+		item, e := p.parsePrimary()
+		if e != nil {
+			err = e
+			return
+		}
+
+		if item.kind != nodeLiteral ||
+			(item.valType != String && item.valType != Int) {
+
+			err = &SyntaxError{
+				Position: item.pos,
+				Message:  "list items must be string or int literals",
+			}
+			return
+		}
+
+		nde.children = append(nde.children, item)
+
+		tok := p.next()
+		if tok.kind == tokenRightBracket {
+			return
+		}
+		if tok.kind != tokenComma {
+			err = p.unexpected(tok, "expected ',' or ']'")
+			return
+		}
+	}
+}
+
+func (p *parser) parseCall(ident *token) (nde *node, err error) {
+	fn := functions[ident.value]
+	if fn == nil {
+		err = &SyntaxError{
+			Position: ident.pos,
+			Message:  fmt.Sprintf("unknown function '%s'", ident.value),
+		}
+		return
+	}
+
+	nde = &node{
+		kind:     nodeCall,
+		name:     ident.value,
+		children: []*node{},
+		pos:      ident.pos,
+		valType:  fn.result,
+	}
+
+	if p.peek().kind == tokenRightParen {
+		p.next()
+	} else {
+		for {
+			arg, e := p.parseOr()
+			if e != nil {
+				err = e
+				return
+			}
+			nde.children = append(nde.children, arg)
+
+			tok := p.next()
+			if tok.kind == tokenRightParen {
+				break
+			}
+			if tok.kind != tokenComma {
+				err = p.unexpected(tok, "expected ',' or ')'")
+				return
+			}
+		}
+	}
+
+	if len(nde.children) < len(fn.args) ||
+		(!fn.variadic && len(nde.children) > len(fn.args)) {
+
+		err = &SyntaxError{
+			Position: ident.pos,
+			Message: fmt.Sprintf("function '%s' expects %d arguments",
+				ident.value, len(fn.args)),
+		}
+		return
+	}
+
+	for i, arg := range nde.children {
+		argType := fn.args[len(fn.args)-1]
+		if i < len(fn.args) {
+			argType = fn.args[i]
+		}
+
+		err = p.requireType(arg, argType, ident.value)
+		if err != nil {
+			return
+		}
+	}
+
+	if nde.name == "in_network" {
+		for _, arg := range nde.children[1:] {
+			if arg.kind != nodeLiteral {
+				continue
+			}
+
+			_, _, e := net.ParseCIDR(arg.value.(string))
+			if e != nil {
+				err = &SyntaxError{
+					Position: arg.pos,
+					Message: fmt.Sprintf("invalid network '%s'",
+						arg.value.(string)),
+				}
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (p *parser) parsePrimary() (nde *node, err error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenLeftParen:
+		nde, err = p.parseOr()
+		if err != nil {
+			return
+		}
+
+		_, err = p.expect(tokenRightParen, ")")
+		if err != nil {
+			return
+		}
+		return
+	case tokenLeftBracket:
+		nde, err = p.parseList(tok)
+		return
+	case tokenString:
+		nde = &node{
+			kind:    nodeLiteral,
+			value:   tok.value,
+			pos:     tok.pos,
+			valType: String,
+		}
+		return
+	case tokenInt:
+		val, _ := strconv.ParseInt(tok.value, 10, 64)
+
+		nde = &node{
+			kind:    nodeLiteral,
+			value:   val,
+			pos:     tok.pos,
+			valType: Int,
+		}
+		return
+	case tokenIdent:
+		if tok.value == "true" || tok.value == "false" {
+			nde = &node{
+				kind:    nodeLiteral,
+				value:   tok.value == "true",
+				pos:     tok.pos,
+				valType: Bool,
+			}
+			return
+		}
+
+		if p.peek().kind == tokenLeftParen {
+			p.next()
+			nde, err = p.parseCall(tok)
+			return
+		}
+
+		typ, ok := Variables[tok.value]
+		if !ok {
+			err = &SyntaxError{
+				Position: tok.pos,
+				Message:  fmt.Sprintf("unknown variable '%s'", tok.value),
+			}
+			return
+		}
+		p.variables[tok.value] = true
+
+		nde = &node{
+			kind:    nodeVariable,
+			name:    tok.value,
+			pos:     tok.pos,
+			valType: typ,
+		}
+		return
+	}
+
+	err = p.unexpected(tok, "expected value")
+	return
+}
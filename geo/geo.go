@@ -99,7 +99,9 @@ func get(addr string) (ge *Geo, err error) {
 	return
 }
 
-func Get(db *database.Database, addr string) (ge *Geo, err error) {
+func lookup(db *database.Database, addr string) (ge *Geo, fetched bool,
+	err error) {
+
 	ge = &Geo{}
 	coll := db.Geo()
 
@@ -121,8 +123,7 @@ func Get(db *database.Database, addr string) (ge *Geo, err error) {
 		}
 
 		if ge != nil {
-			ge.Timestamp = time.Now()
-			coll.InsertOne(db, ge)
+			fetched = true
 		} else {
 			ge = &Geo{}
 		}
@@ -130,3 +131,26 @@ func Get(db *database.Database, addr string) (ge *Geo, err error) {
 
 	return
 }
+
+func Get(db *database.Database, addr string) (ge *Geo, err error) {
+	ge, fetched, err := lookup(db, addr)
+	if err != nil {
+		return
+	}
+
+	if fetched {
+		ge.Timestamp = time.Now()
+		db.Geo().InsertOne(db, ge)
+	}
+
+	return
+}
+
+func Lookup(db *database.Database, addr string) (ge *Geo, err error) {
+	ge, _, err = lookup(db, addr)
+	if err != nil {
+		return
+	}
+
+	return
+}
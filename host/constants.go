@@ -0,0 +1,5 @@
+package host
+
+const (
+	MaxRenewals = 30
+)
@@ -0,0 +1,71 @@
+package host
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/database"
+)
+
+type Renewal struct {
+	CertificateId primitive.ObjectID `bson:"certificate_id" json:"certificate_id"`
+	Serial        string             `bson:"serial" json:"serial"`
+	Fingerprint   string             `bson:"fingerprint" json:"fingerprint"`
+	Timestamp     time.Time          `bson:"timestamp" json:"timestamp"`
+	Expires       time.Time          `bson:"expires" json:"expires"`
+}
+
+type Host struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Hostname    string             `bson:"hostname" json:"hostname"`
+	AuthorityId primitive.ObjectID `bson:"authority_id" json:"authority_id"`
+	Domain      string             `bson:"domain" json:"domain"`
+	PublicKey   string             `bson:"public_key" json:"public_key"`
+	Fingerprint string             `bson:"fingerprint" json:"fingerprint"`
+	FirstSeen   time.Time          `bson:"first_seen" json:"first_seen"`
+	LastSeen    time.Time          `bson:"last_seen" json:"last_seen"`
+	Agent       *agent.Agent       `bson:"agent" json:"agent"`
+	Serial      string             `bson:"serial" json:"serial"`
+	Principals  []string           `bson:"principals" json:"principals"`
+	Expires     time.Time          `bson:"expires" json:"expires"`
+	Renewals    []*Renewal         `bson:"renewals" json:"renewals"`
+	Expired     bool               `bson:"-" json:"expired"`
+}
+
+func (h *Host) Json() {
+	h.Expired = !h.Expires.IsZero() && time.Now().After(h.Expires)
+
+	if h.Principals == nil {
+		h.Principals = []string{}
+	}
+
+	if h.Renewals == nil {
+		h.Renewals = []*Renewal{}
+	}
+}
+
+func (h *Host) Commit(db *database.Database) (err error) {
+	coll := db.Hosts()
+
+	err = coll.Commit(h.Id, h)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (h *Host) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.Hosts()
+
+	err = coll.CommitFields(h.Id, h, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
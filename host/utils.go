@@ -0,0 +1,181 @@
+package host
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+func Fingerprint(pubKey string) (fingerprint string, err error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pubKey))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "host: Failed to parse host public key"),
+		}
+		return
+	}
+
+	fingerprint = ssh.FingerprintSHA256(key)
+
+	return
+}
+
+func Record(db *database.Database, hostname string,
+	authrId primitive.ObjectID, domain, pubKey string, agnt *agent.Agent,
+	certId primitive.ObjectID, serial string, principals []string,
+	expires time.Time) (
+	err error) {
+
+	pubKey = strings.TrimSpace(pubKey)
+
+	fingerprint, err := Fingerprint(pubKey)
+	if err != nil {
+		return
+	}
+
+	coll := db.Hosts()
+	now := time.Now()
+
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+
+	_, err = coll.UpdateOne(db, &bson.M{
+		"hostname":     hostname,
+		"authority_id": authrId,
+	}, &bson.M{
+		"$set": &bson.M{
+			"domain":      domain,
+			"public_key":  pubKey,
+			"fingerprint": fingerprint,
+			"last_seen":   now,
+			"agent":       agnt,
+			"serial":      serial,
+			"principals":  principals,
+			"expires":     expires,
+		},
+		"$setOnInsert": &bson.M{
+			"first_seen": now,
+		},
+		"$push": &bson.M{
+			"renewals": &bson.M{
+				"$each": []*Renewal{
+					{
+						CertificateId: certId,
+						Serial:        serial,
+						Fingerprint:   fingerprint,
+						Timestamp:     now,
+						Expires:       expires,
+					},
+				},
+				"$slice": -MaxRenewals,
+			},
+		},
+	}, opts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Get(db *database.Database, hostId primitive.ObjectID) (
+	hst *Host, err error) {
+
+	coll := db.Hosts()
+	hst = &Host{}
+
+	err = coll.FindOneId(hostId, hst)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M, page, pageCount int64) (
+	hosts []*Host, count int64, err error) {
+
+	coll := db.Hosts()
+	hosts = []*Host{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	opts := options.FindOptions{
+		Sort: &bson.D{
+			{"hostname", 1},
+		},
+		Projection: &bson.D{
+			{"renewals", 0},
+		},
+	}
+
+	if pageCount != 0 {
+		page = utils.Min64(page, count/pageCount)
+		skip := utils.Min64(page*pageCount, count)
+		opts.Skip = &skip
+		opts.Limit = &pageCount
+	}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&opts,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		hst := &Host{}
+		err = cursor.Decode(hst)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		hosts = append(hosts, hst)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, hostId primitive.ObjectID) (err error) {
+	coll := db.Hosts()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": hostId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,404 @@
+package hostagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+type hostData struct {
+	Hostname  string   `json:"hostname"`
+	Aliases   []string `json:"aliases"`
+	Port      int      `json:"port"`
+	Tokens    []string `json:"tokens"`
+	PublicKey string   `json:"public_key"`
+}
+
+type hostCertificateData struct {
+	Certificates []string `json:"certificates"`
+}
+
+type errorData struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+type Agent struct {
+	conf      *Config
+	challenge *challengeServer
+	client    *http.Client
+	backoff   time.Duration
+	stop      chan bool
+}
+
+func (a *Agent) request(method, url string, body interface{}) (
+	data []byte, err error) {
+
+	var reqBody *bytes.Buffer
+	if body != nil {
+		bodyData, e := json.Marshal(body)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "hostagent: Failed to marshal request"),
+			}
+			return
+		}
+		reqBody = bytes.NewBuffer(bodyData)
+	} else {
+		reqBody = &bytes.Buffer{}
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "hostagent: Failed to create request"),
+		}
+		return
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "hostagent: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "hostagent: Failed to read response"),
+		}
+		return
+	}
+
+	if resp.StatusCode != 200 {
+		errData := &errorData{}
+		json.Unmarshal(data, errData)
+
+		if errData.Message != "" {
+			err = &errortypes.RequestError{
+				errors.Newf("hostagent: Request failed, %s (%s)",
+					errData.Message, errData.Error),
+			}
+		} else {
+			err = &errortypes.RequestError{
+				errors.Newf("hostagent: Request bad status %d",
+					resp.StatusCode),
+			}
+		}
+		return
+	}
+
+	return
+}
+
+func (a *Agent) requestCertificate() (cert *ssh.Certificate,
+	certStr string, err error) {
+
+	data, err := a.request("POST", a.conf.Server+"/ssh/host", &hostData{
+		Hostname:  a.conf.Hostname,
+		Aliases:   a.conf.Aliases,
+		Port:      a.conf.Port,
+		Tokens:    a.conf.Tokens,
+		PublicKey: a.challenge.publicKey,
+	})
+	if err != nil {
+		return
+	}
+
+	certData := &hostCertificateData{}
+	err = json.Unmarshal(data, certData)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "hostagent: Failed to parse host certificate"),
+		}
+		return
+	}
+
+	if len(certData.Certificates) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("hostagent: No host certificates returned"),
+		}
+		return
+	}
+
+	if len(certData.Certificates) > 1 {
+		logrus.WithFields(logrus.Fields{
+			"count": len(certData.Certificates),
+		}).Warn("hostagent: Multiple host certificates issued, " +
+			"installing first certificate")
+	}
+
+	certStr = strings.TrimSpace(certData.Certificates[0]) + "\n"
+
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certStr))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "hostagent: Failed to parse host certificate"),
+		}
+		return
+	}
+
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("hostagent: Invalid host certificate"),
+		}
+		return
+	}
+
+	return
+}
+
+func (a *Agent) requestTrusted() (trusted string, err error) {
+	data, err := a.request("GET", fmt.Sprintf("%s/ssh_public_key/%s",
+		a.conf.AdminServer, strings.Join(a.conf.Authorities, ",")), nil)
+	if err != nil {
+		return
+	}
+
+	trusted = strings.TrimSpace(string(data))
+	if trusted == "" {
+		err = &errortypes.ParseError{
+			errors.New("hostagent: Empty trusted public keys"),
+		}
+		return
+	}
+	trusted += "\n"
+
+	return
+}
+
+func (a *Agent) writeFile(path, data string) (changed bool, err error) {
+	cur, e := ioutil.ReadFile(path)
+	if e == nil && string(cur) == data {
+		return
+	}
+
+	err = utils.CreateWrite(path, data, 0644)
+	if err != nil {
+		return
+	}
+	changed = true
+
+	return
+}
+
+func (a *Agent) updateSshdConfig() (changed bool, err error) {
+	data, err := ioutil.ReadFile(a.conf.SshdConfig)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "hostagent: Failed to read sshd config"),
+		}
+		return
+	}
+
+	directives := [][]string{
+		{"HostCertificate", a.conf.HostCertificate},
+	}
+	if len(a.conf.Authorities) != 0 {
+		directives = append(directives,
+			[]string{"TrustedUserCAKeys", a.conf.TrustedKeys})
+	}
+
+	lines := strings.Split(string(data), "\n")
+	missing := []string{}
+
+	for _, directive := range directives {
+		found := false
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) == 2 &&
+				strings.EqualFold(fields[0], directive[0]) &&
+				fields[1] == directive[1] {
+
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, strings.Join(directive, " "))
+		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	// Directives must come before any Match block and sshd uses the
+	// first value found, prepend to take precedence.
+	output := strings.Join(missing, "\n") + "\n" + string(data)
+
+	err = utils.CreateWrite(a.conf.SshdConfig, output, 0644)
+	if err != nil {
+		return
+	}
+	changed = true
+
+	logrus.WithFields(logrus.Fields{
+		"path":       a.conf.SshdConfig,
+		"directives": missing,
+	}).Info("hostagent: Updated sshd config")
+
+	return
+}
+
+func (a *Agent) reload() (err error) {
+	err = utils.Exec("", a.conf.ReloadCommand[0],
+		a.conf.ReloadCommand[1:]...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (a *Agent) sync() (next time.Duration, err error) {
+	cert, certStr, err := a.requestCertificate()
+	if err != nil {
+		return
+	}
+
+	reload, err := a.writeFile(a.conf.HostCertificate, certStr)
+	if err != nil {
+		return
+	}
+
+	if len(a.conf.Authorities) != 0 {
+		trusted, e := a.requestTrusted()
+		if e != nil {
+			err = e
+			return
+		}
+
+		changed, e := a.writeFile(a.conf.TrustedKeys, trusted)
+		if e != nil {
+			err = e
+			return
+		}
+		reload = reload || changed
+	}
+
+	changed, err := a.updateSshdConfig()
+	if err != nil {
+		return
+	}
+	reload = reload || changed
+
+	if reload {
+		err = a.reload()
+		if err != nil {
+			return
+		}
+	}
+
+	validAfter := time.Unix(int64(cert.ValidAfter), 0)
+	validBefore := time.Unix(int64(cert.ValidBefore), 0)
+	renew := validAfter.Add(time.Duration(
+		float64(validBefore.Sub(validAfter)) * DefaultRenewRatio))
+
+	next = time.Until(renew)
+	if next < DefaultRenewMinimum {
+		next = DefaultRenewMinimum
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"hostname":   a.conf.Hostname,
+		"serial":     cert.Serial,
+		"principals": cert.ValidPrincipals,
+		"expires":    validBefore,
+		"renew":      time.Now().Add(next),
+	}).Info("hostagent: Host certificate updated")
+
+	return
+}
+
+func (a *Agent) nextBackoff() (wait time.Duration) {
+	if a.backoff == 0 {
+		a.backoff = DefaultBackoffMinimum
+	} else {
+		a.backoff *= 2
+		if a.backoff > DefaultBackoffMaximum {
+			a.backoff = DefaultBackoffMaximum
+		}
+	}
+
+	wait = a.backoff + time.Duration(rand.Int63n(int64(a.backoff)/4+1))
+
+	return
+}
+
+func (a *Agent) Run() (err error) {
+	err = a.challenge.Start()
+	if err != nil {
+		return
+	}
+	defer a.challenge.Stop()
+
+	for {
+		wait, e := a.sync()
+		if e != nil {
+			wait = a.nextBackoff()
+
+			logrus.WithFields(logrus.Fields{
+				"hostname": a.conf.Hostname,
+				"retry":    wait.String(),
+				"error":    e,
+			}).Error("hostagent: Failed to renew host certificate")
+		} else {
+			a.backoff = 0
+		}
+
+		select {
+		case <-a.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (a *Agent) Stop() {
+	close(a.stop)
+}
+
+func New(conf *Config) (agnt *Agent, err error) {
+	_, err = os.Stat(conf.HostKey + ".pub")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "hostagent: Host public key not found"),
+		}
+		return
+	}
+
+	agnt = &Agent{
+		conf: conf,
+		challenge: &challengeServer{
+			conf: conf,
+		},
+		client: &http.Client{
+			Timeout: DefaultRequestTimeout,
+		},
+		stop: make(chan bool),
+	}
+
+	return
+}
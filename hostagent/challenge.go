@@ -0,0 +1,176 @@
+package hostagent
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"golang.org/x/crypto/ssh"
+)
+
+type challengeData struct {
+	PublicKey string `json:"public_key"`
+}
+
+type challengeServer struct {
+	conf      *Config
+	publicKey string
+	server    *http.Server
+}
+
+func (c *challengeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" || r.URL.Path != "/challenge" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := json.Marshal(&challengeData{
+		PublicKey: c.publicKey,
+	})
+	if err != nil {
+		http.Error(w, "", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (c *challengeServer) tlsCertificate() (cert tls.Certificate, err error) {
+	keyData, err := ioutil.ReadFile(c.conf.HostKey)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "hostagent: Failed to read host private key"),
+		}
+		return
+	}
+
+	rawKey, err := ssh.ParseRawPrivateKey(keyData)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "hostagent: Failed to parse host private key"),
+		}
+		return
+	}
+
+	if edKey, ok := rawKey.(*ed25519.PrivateKey); ok {
+		rawKey = *edKey
+	}
+
+	privateKey, ok := rawKey.(crypto.Signer)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("hostagent: Unsupported host private key"),
+		}
+		return
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "hostagent: Failed to generate serial"),
+		}
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: c.conf.Hostname,
+		},
+		NotBefore:   time.Now().Add(-5 * time.Minute),
+		NotAfter:    time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template,
+		template, privateKey.Public(), privateKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "hostagent: Failed to create tls certificate"),
+		}
+		return
+	}
+
+	cert = tls.Certificate{
+		Certificate: [][]byte{certBytes},
+		PrivateKey:  privateKey,
+	}
+
+	return
+}
+
+func (c *challengeServer) Start() (err error) {
+	pubKey, err := ioutil.ReadFile(c.conf.HostKey + ".pub")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "hostagent: Failed to read host public key"),
+		}
+		return
+	}
+	c.publicKey = strings.TrimSpace(string(pubKey))
+
+	c.server = &http.Server{
+		Addr:         fmt.Sprintf(":%d", c.conf.Port),
+		Handler:      c,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	if c.conf.Https {
+		cert, e := c.tlsCertificate()
+		if e != nil {
+			err = e
+			return
+		}
+
+		c.server.TLSConfig = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+
+	go func() {
+		var e error
+		if c.conf.Https {
+			e = c.server.ListenAndServeTLS("", "")
+		} else {
+			e = c.server.ListenAndServe()
+		}
+		if e != nil && e != http.ErrServerClosed {
+			logrus.WithFields(logrus.Fields{
+				"port":  c.conf.Port,
+				"error": e,
+			}).Error("hostagent: Challenge server error")
+		}
+	}()
+
+	return
+}
+
+func (c *challengeServer) Stop() {
+	if c.server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+
+	c.server.Shutdown(ctx)
+}
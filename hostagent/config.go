@@ -0,0 +1,119 @@
+package hostagent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+type Config struct {
+	Server          string   `json:"server"`
+	AdminServer     string   `json:"admin_server"`
+	Tokens          []string `json:"tokens"`
+	Authorities     []string `json:"authorities"`
+	Hostname        string   `json:"hostname"`
+	Aliases         []string `json:"aliases"`
+	Port            int      `json:"port"`
+	Https           bool     `json:"https"`
+	HostKey         string   `json:"host_key"`
+	HostCertificate string   `json:"host_certificate"`
+	TrustedKeys     string   `json:"trusted_keys"`
+	SshdConfig      string   `json:"sshd_config"`
+	ReloadCommand   []string `json:"reload_command"`
+}
+
+func (c *Config) Validate() (err error) {
+	c.Server = strings.TrimRight(strings.TrimSpace(c.Server), "/")
+	c.AdminServer = strings.TrimRight(
+		strings.TrimSpace(c.AdminServer), "/")
+
+	if c.Server == "" {
+		err = &errortypes.ParseError{
+			errors.New("hostagent: Missing server in config"),
+		}
+		return
+	}
+
+	if len(c.Tokens) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("hostagent: Missing tokens in config"),
+		}
+		return
+	}
+
+	if c.AdminServer == "" {
+		c.AdminServer = c.Server
+	}
+
+	if c.Hostname == "" {
+		hostname, e := os.Hostname()
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "hostagent: Failed to get hostname"),
+			}
+			return
+		}
+
+		c.Hostname = strings.SplitN(hostname, ".", 2)[0]
+	}
+
+	if c.Port == 0 {
+		c.Port = DefaultPort
+	}
+
+	if c.HostKey == "" {
+		c.HostKey = DefaultHostKey
+	}
+
+	if c.HostCertificate == "" {
+		c.HostCertificate = c.HostKey + "-cert.pub"
+	}
+
+	if c.TrustedKeys == "" {
+		c.TrustedKeys = DefaultTrustedKeys
+	}
+
+	if c.SshdConfig == "" {
+		c.SshdConfig = DefaultSshdConfig
+	}
+
+	if len(c.ReloadCommand) == 0 {
+		c.ReloadCommand = DefaultReloadCommand
+	}
+
+	return
+}
+
+func LoadConfig(path string) (conf *Config, err error) {
+	if path == "" {
+		path = DefaultConfPath
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "hostagent: Failed to read config file"),
+		}
+		return
+	}
+
+	conf = &Config{}
+	err = json.Unmarshal(data, conf)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "hostagent: Failed to parse config file"),
+		}
+		return
+	}
+
+	err = conf.Validate()
+	if err != nil {
+		return
+	}
+
+	return
+}
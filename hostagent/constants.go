@@ -0,0 +1,23 @@
+package hostagent
+
+import (
+	"time"
+)
+
+const (
+	DefaultConfPath        = "/etc/pritunl-zero-host.json"
+	DefaultPort            = 9748
+	DefaultHostKey         = "/etc/ssh/ssh_host_ed25519_key"
+	DefaultTrustedKeys     = "/etc/ssh/trusted"
+	DefaultSshdConfig      = "/etc/ssh/sshd_config"
+	DefaultRenewRatio      = 0.66
+	DefaultRenewMinimum    = 1 * time.Minute
+	DefaultBackoffMinimum  = 10 * time.Second
+	DefaultBackoffMaximum  = 15 * time.Minute
+	DefaultRequestTimeout  = 30 * time.Second
+	DefaultShutdownTimeout = 5 * time.Second
+)
+
+var (
+	DefaultReloadCommand = []string{"systemctl", "reload", "sshd"}
+)
@@ -18,6 +18,7 @@ Usage: pritunl-zero COMMAND
 Commands:
   version           Show version
   mongo             Set MongoDB URI
+  pkcs11-pin        Set PKCS#11 token PIN for this node
   set               Set a setting
   unset             Unset a setting
   start             Start node
@@ -26,6 +27,9 @@ Commands:
   reset-password    Reset administrator password
   disable-policies  Disable all policies
   export-ssh        Export SSH authorities for emergency client
+  import-ssh        Import SSH authorities from export, --dry-run to preview
+  host-agent        Run host certificate agent, optional config path
+  ssh-login         Request SSH certificate, --agent to add to ssh-agent
 `
 
 func Init() {
@@ -61,6 +65,13 @@ func main() {
 			panic(err)
 		}
 		return
+	case "pkcs11-pin":
+		logger.Init()
+		err := cmd.Pkcs11Pin()
+		if err != nil {
+			panic(err)
+		}
+		return
 	case "reset-id":
 		logger.Init()
 		err := cmd.ResetId()
@@ -110,6 +121,27 @@ func main() {
 			panic(err)
 		}
 		return
+	case "import-ssh":
+		Init()
+		err := cmd.ImportSsh()
+		if err != nil {
+			panic(err)
+		}
+		return
+	case "host-agent":
+		logger.Init()
+		err := cmd.HostAgent()
+		if err != nil {
+			panic(err)
+		}
+		return
+	case "ssh-login":
+		logger.Init()
+		err := cmd.SshLogin()
+		if err != nil {
+			panic(err)
+		}
+		return
 	case "clear-logs":
 		Init()
 		err := cmd.ClearLogs()
@@ -10,31 +10,44 @@ import (
 	"github.com/pritunl/pritunl-zero/database"
 	"github.com/pritunl/pritunl-zero/demo"
 	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/revocation"
 	"github.com/pritunl/pritunl-zero/utils"
 )
 
 type authorityData struct {
-	Id                 primitive.ObjectID `json:"id"`
-	Name               string             `json:"name"`
-	Type               string             `json:"type"`
-	Algorithm          string             `json:"algorithm"`
-	Expire             int                `json:"expire"`
-	HostExpire         int                `json:"host_expire"`
-	MatchRoles         bool               `json:"match_roles"`
-	Roles              []string           `json:"roles"`
-	ProxyHosting       bool               `json:"proxy_hosting"`
-	ProxyHostname      string             `json:"proxy_hostname"`
-	ProxyPort          int                `json:"proxy_port"`
-	HostDomain         string             `json:"host_domain"`
-	HostMatches        []string           `json:"host_matches"`
-	HostSubnets        []string           `json:"host_subnets"`
-	HostProxy          string             `json:"host_proxy"`
-	HostCertificates   bool               `json:"host_certificates"`
-	StrictHostChecking bool               `json:"strict_host_checking"`
-	HsmToken           string             `json:"hsm_token"`
-	HsmSecret          string             `json:"hsm_secret"`
-	HsmSerial          string             `json:"hsm_serial"`
-	HsmGenerateSecret  bool               `json:"hsm_generate_secret"`
+	Id                 primitive.ObjectID            `json:"id"`
+	Name               string                        `json:"name"`
+	Type               string                        `json:"type"`
+	Algorithm          string                        `json:"algorithm"`
+	Expire             int                           `json:"expire"`
+	RoleExpires        []*authority.RoleExpire       `json:"role_expires"`
+	HostExpire         int                           `json:"host_expire"`
+	KeyOverlap         int                           `json:"key_overlap"`
+	MatchRoles         bool                          `json:"match_roles"`
+	Roles              []string                      `json:"roles"`
+	ProxyHosting       bool                          `json:"proxy_hosting"`
+	ProxyHostname      string                        `json:"proxy_hostname"`
+	ProxyPort          int                           `json:"proxy_port"`
+	HostDomain         string                        `json:"host_domain"`
+	HostMatches        []string                      `json:"host_matches"`
+	HostSubnets        []string                      `json:"host_subnets"`
+	HostProxy          string                        `json:"host_proxy"`
+	HostValidation     string                        `json:"host_validation"`
+	HostCertificates   bool                          `json:"host_certificates"`
+	StrictHostChecking bool                          `json:"strict_host_checking"`
+	CertificateProfile *authority.CertificateProfile `json:"certificate_profile"`
+	PrincipalTemplates []string                      `json:"principal_templates"`
+	PrincipalMappings  []*authority.PrincipalMapping `json:"principal_mappings"`
+	ApprovalRequired   bool                          `json:"approval_required"`
+	ApprovalRoles      []string                      `json:"approval_roles"`
+	ApprovalExpire     int                           `json:"approval_expire"`
+	HsmToken           string                        `json:"hsm_token"`
+	HsmSecret          string                        `json:"hsm_secret"`
+	HsmSerial          string                        `json:"hsm_serial"`
+	HsmGenerateSecret  bool                          `json:"hsm_generate_secret"`
+	Pkcs11Module       string                        `json:"pkcs11_module"`
+	Pkcs11Token        string                        `json:"pkcs11_token"`
+	Pkcs11Key          string                        `json:"pkcs11_key"`
 }
 
 func authorityPut(c *gin.Context) {
@@ -78,7 +91,9 @@ func authorityPut(c *gin.Context) {
 	authr.Name = data.Name
 	authr.Type = data.Type
 	authr.Expire = data.Expire
+	authr.RoleExpires = data.RoleExpires
 	authr.HostExpire = data.HostExpire
+	authr.KeyOverlap = data.KeyOverlap
 	authr.MatchRoles = data.MatchRoles
 	authr.Roles = data.Roles
 
@@ -96,9 +111,19 @@ func authorityPut(c *gin.Context) {
 	authr.HostSubnets = data.HostSubnets
 	authr.HostDomain = data.HostDomain
 	authr.HostProxy = data.HostProxy
+	authr.HostValidation = data.HostValidation
 	authr.HostCertificates = data.HostCertificates
 	authr.StrictHostChecking = data.StrictHostChecking
+	authr.CertificateProfile = data.CertificateProfile
+	authr.PrincipalTemplates = data.PrincipalTemplates
+	authr.PrincipalMappings = data.PrincipalMappings
+	authr.ApprovalRequired = data.ApprovalRequired
+	authr.ApprovalRoles = data.ApprovalRoles
+	authr.ApprovalExpire = data.ApprovalExpire
 	authr.HsmSerial = data.HsmSerial
+	authr.Pkcs11Module = data.Pkcs11Module
+	authr.Pkcs11Token = data.Pkcs11Token
+	authr.Pkcs11Key = data.Pkcs11Key
 
 	if authr.Type == authority.PritunlHsm && data.HsmGenerateSecret {
 		err = authr.GenerateHsmToken()
@@ -113,7 +138,9 @@ func authorityPut(c *gin.Context) {
 		"name",
 		"type",
 		"expire",
+		"role_expires",
 		"host_expire",
+		"key_overlap",
 		"public_key",
 		"public_key_pem",
 		"root_certificate",
@@ -129,11 +156,21 @@ func authorityPut(c *gin.Context) {
 		"host_subnets",
 		"host_tokens",
 		"host_proxy",
+		"host_validation",
 		"host_certificates",
 		"strict_host_checking",
+		"certificate_profile",
+		"principal_templates",
+		"principal_mappings",
+		"approval_required",
+		"approval_roles",
+		"approval_expire",
 		"hsm_token",
 		"hsm_secret",
 		"hsm_serial",
+		"pkcs11_module",
+		"pkcs11_token",
+		"pkcs11_key",
 	)
 
 	errData, err := authr.Validate(db)
@@ -185,7 +222,9 @@ func authorityPost(c *gin.Context) {
 		Type:               data.Type,
 		Algorithm:          data.Algorithm,
 		Expire:             data.Expire,
+		RoleExpires:        data.RoleExpires,
 		HostExpire:         data.HostExpire,
+		KeyOverlap:         data.KeyOverlap,
 		MatchRoles:         data.MatchRoles,
 		Roles:              data.Roles,
 		ProxyHosting:       data.ProxyHosting,
@@ -194,7 +233,17 @@ func authorityPost(c *gin.Context) {
 		HostDomain:         data.HostDomain,
 		HostMatches:        data.HostMatches,
 		HostSubnets:        data.HostSubnets,
+		HostValidation:     data.HostValidation,
 		StrictHostChecking: data.StrictHostChecking,
+		CertificateProfile: data.CertificateProfile,
+		PrincipalTemplates: data.PrincipalTemplates,
+		PrincipalMappings:  data.PrincipalMappings,
+		ApprovalRequired:   data.ApprovalRequired,
+		ApprovalRoles:      data.ApprovalRoles,
+		ApprovalExpire:     data.ApprovalExpire,
+		Pkcs11Module:       data.Pkcs11Module,
+		Pkcs11Token:        data.Pkcs11Token,
+		Pkcs11Key:          data.Pkcs11Key,
 	}
 
 	err = authr.GeneratePrivateKey()
@@ -336,12 +385,104 @@ func authorityPublicKeyGet(c *gin.Context) {
 	}
 
 	for _, authr := range authrs {
-		publicKeys += strings.TrimSpace(authr.PublicKey) + "\n"
+		for _, publicKey := range authr.GetPublicKeys() {
+			publicKeys += publicKey + "\n"
+		}
 	}
 
 	c.String(200, publicKeys)
 }
 
+func authorityKrlGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	authrIdsStr := strings.Split(c.Param("authr_ids"), ",")
+	authrIds := []primitive.ObjectID{}
+
+	for _, authrIdStr := range authrIdsStr {
+		if authrIdStr == "" {
+			continue
+		}
+
+		authrId, ok := utils.ParseObjectId(authrIdStr)
+		if !ok {
+			utils.AbortWithStatus(c, 400)
+			return
+		}
+
+		authrIds = append(authrIds, authrId)
+	}
+
+	if len(authrIds) == 0 {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	authrs, err := authority.GetMulti(db, authrIds)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	revokes, err := revocation.GetAuthorities(db, authrIds)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.Data(200, "application/octet-stream", revocation.Krl(authrs, revokes))
+}
+
+func authorityRotatePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	authrId, ok := utils.ParseObjectId(c.Param("authr_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	authr, err := authority.Get(db, authrId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err := authr.RotateKey(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = authr.CommitFields(db, set.NewSet(
+		"info",
+		"private_key",
+		"public_key",
+		"public_key_pem",
+		"root_certificate",
+		"rotated_keys",
+	))
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "authority.change")
+
+	authr.Json()
+
+	c.JSON(200, authr)
+}
+
 func authorityTokenPost(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -0,0 +1,554 @@
+package mhandlers
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/audit"
+	"github.com/pritunl/pritunl-zero/authorizer"
+	"github.com/pritunl/pritunl-zero/breakglass"
+	"github.com/pritunl/pritunl-zero/cookie"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/demo"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/node"
+	"github.com/pritunl/pritunl-zero/session"
+	"github.com/pritunl/pritunl-zero/ssh"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+type breakGlassData struct {
+	Id          primitive.ObjectID   `json:"id"`
+	Name        string               `json:"name"`
+	Comment     string               `json:"comment"`
+	UserId      primitive.ObjectID   `json:"user_id"`
+	Authorities []primitive.ObjectID `json:"authorities"`
+	Shares      int                  `json:"shares"`
+	Threshold   int                  `json:"threshold"`
+	Duration    int                  `json:"duration"`
+}
+
+type breakGlassSealData struct {
+	Account *breakglass.Account `json:"account"`
+	Shares  []string            `json:"shares"`
+}
+
+type breakGlassUnlockData struct {
+	Name      string   `json:"name"`
+	Shares    []string `json:"shares"`
+	PublicKey string   `json:"public_key"`
+}
+
+type breakGlassUnlockRespData struct {
+	Expires                time.Time   `json:"expires"`
+	Certificates           []string    `json:"certificates"`
+	CertificateAuthorities []string    `json:"certificate_authorities"`
+	Hosts                  []*ssh.Host `json:"hosts"`
+	CertificateError       string      `json:"certificate_error"`
+}
+
+func breakGlassGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	acctId, ok := utils.ParseObjectId(c.Param("breakglass_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	acct, err := breakglass.Get(db, acctId)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			utils.AbortWithStatus(c, 404)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	c.JSON(200, acct)
+}
+
+func breakGlassesGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	accounts, err := breakglass.GetAll(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, accounts)
+}
+
+func breakGlassPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &breakGlassData{}
+
+	acctId, ok := utils.ParseObjectId(c.Param("breakglass_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	acct, err := breakglass.Get(db, acctId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"user_id",
+		"authorities",
+		"shares",
+		"threshold",
+		"duration",
+	)
+
+	if acct.UserId != data.UserId || acct.Shares != data.Shares ||
+		acct.Threshold != data.Threshold {
+
+		if acct.Unlocked {
+			c.JSON(400, &errortypes.ErrorData{
+				Error: "breakglass_unlocked",
+				Message: "Break-glass account must be locked before " +
+					"changing user or shares",
+			})
+			return
+		}
+
+		acct.Sealed = false
+		acct.SecretHash = ""
+		fields.Add("sealed")
+		fields.Add("secret_hash")
+	}
+
+	acct.Name = data.Name
+	acct.Comment = data.Comment
+	acct.UserId = data.UserId
+	acct.Authorities = data.Authorities
+	acct.Shares = data.Shares
+	acct.Threshold = data.Threshold
+	acct.Duration = data.Duration
+
+	errData, err := acct.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = acct.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "breakglass.change")
+
+	c.JSON(200, acct)
+}
+
+func breakGlassPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &breakGlassData{
+		Name:      "New Break-Glass Account",
+		Shares:    3,
+		Threshold: 2,
+		Duration:  breakglass.DefaultDuration,
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	acct := &breakglass.Account{
+		Name:        data.Name,
+		Comment:     data.Comment,
+		UserId:      data.UserId,
+		Authorities: data.Authorities,
+		Shares:      data.Shares,
+		Threshold:   data.Threshold,
+		Duration:    data.Duration,
+	}
+
+	errData, err := acct.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = acct.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "breakglass.change")
+
+	c.JSON(200, acct)
+}
+
+func breakGlassDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	acctId, ok := utils.ParseObjectId(c.Param("breakglass_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	acct, err := breakglass.Get(db, acctId)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			c.JSON(200, nil)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	if acct.Unlocked {
+		err = acct.Lock(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		event.PublishDispatch(db, "user.change")
+	}
+
+	err = breakglass.Remove(db, acctId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "breakglass.change")
+
+	c.JSON(200, nil)
+}
+
+func breakGlassSealPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	acctId, ok := utils.ParseObjectId(c.Param("breakglass_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	acct, err := breakglass.Get(db, acctId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	shares, errData, err := acct.Seal(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		acct.UserId,
+		audit.BreakGlassSeal,
+		audit.Fields{
+			"breakglass_id": acct.Id,
+			"name":          acct.Name,
+			"shares":        acct.Shares,
+			"threshold":     acct.Threshold,
+			"admin_id":      usr.Id,
+			"admin":         usr.Username,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "breakglass.change")
+	event.PublishDispatch(db, "user.change")
+
+	c.JSON(200, &breakGlassSealData{
+		Account: acct,
+		Shares:  shares,
+	})
+}
+
+func breakGlassLockPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	acctId, ok := utils.ParseObjectId(c.Param("breakglass_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	acct, err := breakglass.Get(db, acctId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = acct.Lock(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		acct.UserId,
+		audit.BreakGlassLock,
+		audit.Fields{
+			"breakglass_id": acct.Id,
+			"name":          acct.Name,
+			"admin_id":      usr.Id,
+			"admin":         usr.Username,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "breakglass.change")
+	event.PublishDispatch(db, "user.change")
+
+	c.JSON(200, acct)
+}
+
+func breakGlassUnlockPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &breakGlassUnlockData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	source := node.Self.GetRemoteAddr(c.Request)
+
+	wait := breakglass.SourceBlocked(source)
+	if wait > 0 {
+		logrus.WithFields(logrus.Fields{
+			"name":   data.Name,
+			"source": source,
+			"wait":   wait.String(),
+		}).Error("mhandlers: Break-glass unlock blocked for source")
+
+		c.JSON(429, &errortypes.ErrorData{
+			Error:   "breakglass_rate_limited",
+			Message: "Too many failed unlock attempts, try again later",
+		})
+		return
+	}
+
+	acct, err := breakglass.GetName(db, data.Name)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			breakglass.SourceFailed(source)
+			c.JSON(401, &errortypes.ErrorData{
+				Error:   "breakglass_shares_invalid",
+				Message: "Break-glass account shares are not valid",
+			})
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	agnt, err := agent.Parse(db, c.Request)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, errData, err := acct.Unlock(db, data.Shares)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		if errData.Error == "breakglass_shares_invalid" ||
+			errData.Error == "breakglass_shares_insufficient" {
+
+			breakglass.SourceFailed(source)
+		}
+
+		err = audit.New(
+			db,
+			c.Request,
+			acct.UserId,
+			audit.BreakGlassUnlockFailed,
+			audit.Fields{
+				"breakglass_id":   acct.Id,
+				"name":            acct.Name,
+				"error":           errData.Error,
+				"message":         errData.Message,
+				"failed_attempts": acct.FailedAttempts,
+				"source":          source,
+			},
+		)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"breakglass_id":   acct.Id.Hex(),
+			"name":            acct.Name,
+			"error":           errData.Error,
+			"failed_attempts": acct.FailedAttempts,
+			"source":          source,
+		}).Error("mhandlers: Break-glass account unlock failed")
+
+		event.PublishDispatch(db, "breakglass.change")
+
+		c.JSON(401, errData)
+		return
+	}
+
+	breakglass.SourceClear(source)
+
+	logrus.WithFields(logrus.Fields{
+		"breakglass_id": acct.Id.Hex(),
+		"name":          acct.Name,
+		"user_id":       usr.Id.Hex(),
+		"username":      usr.Username,
+		"expires":       acct.Expires,
+	}).Error("mhandlers: Break-glass account unlocked")
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.BreakGlassUnlock,
+		audit.Fields{
+			"breakglass_id": acct.Id,
+			"name":          acct.Name,
+			"expires":       acct.Expires,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "breakglass.change")
+	event.PublishDispatch(db, "user.change")
+
+	resp := &breakGlassUnlockRespData{
+		Expires:                acct.Expires,
+		Certificates:           []string{},
+		CertificateAuthorities: []string{},
+		Hosts:                  []*ssh.Host{},
+	}
+
+	cert, err := acct.IssueCertificate(db, usr, agnt, data.PublicKey)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"breakglass_id": acct.Id.Hex(),
+			"name":          acct.Name,
+			"error":         err,
+		}).Error("mhandlers: Break-glass certificate issue failed")
+
+		resp.CertificateError = "Failed to issue certificate, " +
+			"account remains unlocked"
+	} else if cert != nil {
+		resp.Certificates = cert.Certificates
+		resp.CertificateAuthorities = cert.CertificateAuthorities
+		resp.Hosts = cert.Hosts
+	}
+
+	if usr.Administrator == "super" {
+		cook := cookie.NewAdmin(c.Writer, c.Request)
+
+		_, err = cook.NewSession(db, c.Request, usr.Id, false, session.Admin)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	c.JSON(200, resp)
+}
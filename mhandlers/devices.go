@@ -11,6 +11,7 @@ import (
 	"github.com/pritunl/pritunl-zero/device"
 	"github.com/pritunl/pritunl-zero/errortypes"
 	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/revocation"
 	"github.com/pritunl/pritunl-zero/secondary"
 	"github.com/pritunl/pritunl-zero/settings"
 	"github.com/pritunl/pritunl-zero/u2flib"
@@ -132,7 +133,25 @@ func deviceDelete(c *gin.Context) {
 		return
 	}
 
-	err := device.Remove(db, devcId)
+	devc, err := device.Get(db, devcId)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			c.JSON(200, nil)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	err = device.Remove(db, devcId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = revocation.RevokeDevice(db, devc, "Device deleted")
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -58,10 +58,21 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/authority/:authr_id", authorityPut)
 	csrfGroup.POST("/authority", authorityPost)
 	csrfGroup.DELETE("/authority/:authr_id", authorityDelete)
+	csrfGroup.POST("/authority/:authr_id/rotate", authorityRotatePost)
 	csrfGroup.POST("/authority/:authr_id/token", authorityTokenPost)
 	csrfGroup.DELETE("/authority/:authr_id/token/:token",
 		authorityTokenDelete)
 	dbGroup.GET("/ssh_public_key/:authr_ids", authorityPublicKeyGet)
+	dbGroup.GET("/ssh_krl/:authr_ids", authorityKrlGet)
+
+	csrfGroup.GET("/breakglass", breakGlassesGet)
+	csrfGroup.GET("/breakglass/:breakglass_id", breakGlassGet)
+	csrfGroup.PUT("/breakglass/:breakglass_id", breakGlassPut)
+	csrfGroup.POST("/breakglass", breakGlassPost)
+	csrfGroup.DELETE("/breakglass/:breakglass_id", breakGlassDelete)
+	csrfGroup.POST("/breakglass/:breakglass_id/seal", breakGlassSealPost)
+	csrfGroup.POST("/breakglass/:breakglass_id/lock", breakGlassLockPost)
+	dbGroup.POST("/breakglass_unlock", breakGlassUnlockPost)
 
 	csrfGroup.GET("/certificate", certificatesGet)
 	csrfGroup.GET("/certificate/:cert_id", certificateGet)
@@ -82,6 +93,10 @@ func Register(engine *gin.Engine) {
 
 	csrfGroup.GET("/event", eventGet)
 
+	csrfGroup.GET("/host", hostsGet)
+	csrfGroup.GET("/host/:host_id", hostGet)
+	csrfGroup.DELETE("/host/:host_id", hostDelete)
+
 	csrfGroup.GET("/log", logsGet)
 	csrfGroup.GET("/log/:log_id", logGet)
 
@@ -95,6 +110,12 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/policy/:policy_id", policyPut)
 	csrfGroup.POST("/policy", policyPost)
 	csrfGroup.DELETE("/policy/:policy_id", policyDelete)
+	csrfGroup.POST("/policy/explain", policyExplainPost)
+	csrfGroup.GET("/policy_monitor", policyMonitorGet)
+
+	csrfGroup.GET("/revocation", revocationsGet)
+	csrfGroup.POST("/revocation", revocationPost)
+	csrfGroup.DELETE("/revocation/:revocation_id", revocationDelete)
 
 	csrfGroup.GET("/service", servicesGet)
 	csrfGroup.PUT("/service/:service_id", servicePut)
@@ -107,7 +128,14 @@ func Register(engine *gin.Engine) {
 	csrfGroup.GET("/settings", settingsGet)
 	csrfGroup.PUT("/settings", settingsPut)
 
+	csrfGroup.GET("/sshapproval", approvalsGet)
+	csrfGroup.PUT("/sshapproval/:approval_id", approvalPut)
+	csrfGroup.DELETE("/sshapproval/:approval_id", approvalDelete)
+
 	csrfGroup.GET("/sshcertificate/:user_id", sshcertsGet)
+	csrfGroup.GET("/sshsession/:user_id", sshsessionsGet)
+	csrfGroup.GET("/sshsession/:user_id/:session_id/recording",
+		sshsessionRecordingGet)
 
 	csrfGroup.GET("/subscription", subscriptionGet)
 	csrfGroup.GET("/subscription/update", subscriptionUpdateGet)
@@ -0,0 +1,122 @@
+package mhandlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/demo"
+	"github.com/pritunl/pritunl-zero/host"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+type hostsData struct {
+	Hosts []*host.Host `json:"hosts"`
+	Count int64        `json:"count"`
+}
+
+func hostsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	hostname := strings.TrimSpace(c.Query("hostname"))
+	if hostname != "" {
+		query["hostname"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", regexp.QuoteMeta(hostname)),
+			"$options": "i",
+		}
+	}
+
+	authrId, ok := utils.ParseObjectId(c.Query("authority"))
+	if ok {
+		query["authority_id"] = authrId
+	}
+
+	expired := c.Query("expired")
+	switch expired {
+	case "true":
+		query["expires"] = &bson.M{
+			"$lt": time.Now(),
+		}
+		break
+	case "false":
+		query["expires"] = &bson.M{
+			"$gte": time.Now(),
+		}
+		break
+	}
+
+	hosts, count, err := host.GetAll(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, hst := range hosts {
+		hst.Json()
+	}
+
+	data := &hostsData{
+		Hosts: hosts,
+		Count: count,
+	}
+
+	c.JSON(200, data)
+}
+
+func hostGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	hostId, ok := utils.ParseObjectId(c.Param("host_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	hst, err := host.Get(db, hostId)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			utils.AbortWithStatus(c, 404)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	hst.Json()
+
+	c.JSON(200, hst)
+}
+
+func hostDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	hostId, ok := utils.ParseObjectId(c.Param("host_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := host.Remove(db, hostId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
@@ -1,20 +1,31 @@
 package mhandlers
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/authority"
 	"github.com/pritunl/pritunl-zero/database"
 	"github.com/pritunl/pritunl-zero/demo"
+	"github.com/pritunl/pritunl-zero/errortypes"
 	"github.com/pritunl/pritunl-zero/event"
 	"github.com/pritunl/pritunl-zero/policy"
+	"github.com/pritunl/pritunl-zero/service"
+	"github.com/pritunl/pritunl-zero/user"
 	"github.com/pritunl/pritunl-zero/utils"
+	"github.com/pritunl/pritunl-zero/validator"
 )
 
 type policyData struct {
 	Id                        primitive.ObjectID      `json:"id"`
 	Name                      string                  `json:"name"`
 	Disabled                  bool                    `json:"disabled"`
+	Mode                      string                  `json:"mode"`
 	Services                  []primitive.ObjectID    `json:"services"`
 	Authorities               []primitive.ObjectID    `json:"authorities"`
 	Roles                     []string                `json:"roles"`
@@ -28,6 +39,17 @@ type policyData struct {
 	ProxyDeviceSecondary      bool                    `json:"proxy_device_secondary"`
 	AuthorityDeviceSecondary  bool                    `json:"authority_device_secondary"`
 	AuthorityRequireSmartCard bool                    `json:"authority_require_smart_card"`
+	StepUpSecondary           primitive.ObjectID      `json:"step_up_secondary"`
+	StepUpDeviceSecondary     bool                    `json:"step_up_device_secondary"`
+}
+
+type policyExplainData struct {
+	UserId      primitive.ObjectID `json:"user_id"`
+	ServiceId   primitive.ObjectID `json:"service_id"`
+	AuthorityId primitive.ObjectID `json:"authority_id"`
+	Ip          string             `json:"ip"`
+	UserAgent   string             `json:"user_agent"`
+	Timestamp   time.Time          `json:"timestamp"`
 }
 
 func policyPut(c *gin.Context) {
@@ -58,6 +80,7 @@ func policyPut(c *gin.Context) {
 
 	polcy.Name = data.Name
 	polcy.Disabled = data.Disabled
+	polcy.Mode = data.Mode
 	polcy.Services = data.Services
 	polcy.Authorities = data.Authorities
 	polcy.Roles = data.Roles
@@ -71,10 +94,13 @@ func policyPut(c *gin.Context) {
 	polcy.ProxyDeviceSecondary = data.ProxyDeviceSecondary
 	polcy.AuthorityDeviceSecondary = data.AuthorityDeviceSecondary
 	polcy.AuthorityRequireSmartCard = data.AuthorityRequireSmartCard
+	polcy.StepUpSecondary = data.StepUpSecondary
+	polcy.StepUpDeviceSecondary = data.StepUpDeviceSecondary
 
 	fields := set.NewSet(
 		"name",
 		"disabled",
+		"mode",
 		"services",
 		"authorities",
 		"roles",
@@ -88,6 +114,8 @@ func policyPut(c *gin.Context) {
 		"proxy_device_secondary",
 		"authority_device_secondary",
 		"authority_require_smart_card",
+		"step_up_secondary",
+		"step_up_device_secondary",
 	)
 
 	errData, err := polcy.Validate(db)
@@ -131,6 +159,7 @@ func policyPost(c *gin.Context) {
 	polcy := &policy.Policy{
 		Name:                     data.Name,
 		Disabled:                 data.Disabled,
+		Mode:                     data.Mode,
 		Services:                 data.Services,
 		Authorities:              data.Authorities,
 		Roles:                    data.Roles,
@@ -143,6 +172,8 @@ func policyPost(c *gin.Context) {
 		UserDeviceSecondary:      data.UserDeviceSecondary,
 		ProxyDeviceSecondary:     data.ProxyDeviceSecondary,
 		AuthorityDeviceSecondary: data.AuthorityDeviceSecondary,
+		StepUpSecondary:          data.StepUpSecondary,
+		StepUpDeviceSecondary:    data.StepUpDeviceSecondary,
 	}
 
 	errData, err := polcy.Validate(db)
@@ -220,3 +251,103 @@ func policiesGet(c *gin.Context) {
 
 	c.JSON(200, policies)
 }
+
+func policyExplainPost(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	data := &policyExplainData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := user.Get(db, data.UserId)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			c.JSON(400, &errortypes.ErrorData{
+				Error:   "user_invalid",
+				Message: "User is not valid",
+			})
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	var srvc *service.Service
+	if !data.ServiceId.IsZero() {
+		srvc, err = service.Get(db, data.ServiceId)
+		if err != nil {
+			switch err.(type) {
+			case *database.NotFoundError:
+				c.JSON(400, &errortypes.ErrorData{
+					Error:   "service_invalid",
+					Message: "Service is not valid",
+				})
+				break
+			default:
+				utils.AbortWithError(c, 500, err)
+			}
+			return
+		}
+	}
+
+	var authr *authority.Authority
+	if srvc == nil && !data.AuthorityId.IsZero() {
+		authr, err = authority.Get(db, data.AuthorityId)
+		if err != nil {
+			switch err.(type) {
+			case *database.NotFoundError:
+				c.JSON(400, &errortypes.ErrorData{
+					Error:   "authority_invalid",
+					Message: "Authority is not valid",
+				})
+				break
+			default:
+				utils.AbortWithError(c, 500, err)
+			}
+			return
+		}
+	}
+
+	timestamp := data.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	agnt, err := agent.Simulate(db, strings.TrimSpace(data.Ip),
+		data.UserAgent)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	explain, err := validator.Explain(db, usr, srvc, authr, agnt, timestamp)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, explain)
+}
+
+func policyMonitorGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	days, _ := strconv.Atoi(c.Query("days"))
+	if days <= 0 {
+		days = policy.DefaultMonitorDays
+	}
+	days = utils.Min(days, policy.MaxMonitorDays)
+
+	reports, err := policy.GetMonitorReport(db, days)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, reports)
+}
@@ -0,0 +1,101 @@
+package mhandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/demo"
+	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/revocation"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+type revocationData struct {
+	Type        string             `json:"type"`
+	AuthorityId primitive.ObjectID `json:"authority_id"`
+	Serial      string             `json:"serial"`
+	KeyId       string             `json:"key_id"`
+	PublicKey   string             `json:"public_key"`
+	Comment     string             `json:"comment"`
+}
+
+func revocationPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &revocationData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	revoke := &revocation.Revocation{
+		Type:        data.Type,
+		AuthorityId: data.AuthorityId,
+		Serial:      data.Serial,
+		KeyId:       data.KeyId,
+		PublicKey:   data.PublicKey,
+		Comment:     data.Comment,
+	}
+
+	errData, err := revoke.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = revoke.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "revocation.change")
+
+	c.JSON(200, revoke)
+}
+
+func revocationDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	revokeId, ok := utils.ParseObjectId(c.Param("revocation_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := revocation.Remove(db, revokeId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "revocation.change")
+
+	c.JSON(200, nil)
+}
+
+func revocationsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	revokes, err := revocation.GetAll(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, revokes)
+}
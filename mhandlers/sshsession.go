@@ -0,0 +1,109 @@
+package mhandlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/demo"
+	"github.com/pritunl/pritunl-zero/ssh"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+type sshsessionsData struct {
+	Sessions []*ssh.Session `json:"sessions"`
+	Count    int64          `json:"count"`
+}
+
+func sshsessionsGet(c *gin.Context) {
+	if demo.IsDemo() {
+		data := &sshsessionsData{
+			Sessions: []*ssh.Session{},
+			Count:    0,
+		}
+
+		c.JSON(200, data)
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	userId, ok := utils.ParseObjectId(c.Param("user_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	sessions, count, err := ssh.GetSessions(db, userId, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &sshsessionsData{
+		Sessions: sessions,
+		Count:    count,
+	}
+
+	c.JSON(200, data)
+}
+
+func sshsessionRecordingGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	userId, ok := utils.ParseObjectId(c.Param("user_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	sessId, ok := utils.ParseObjectId(c.Param("session_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	sess, err := ssh.GetSession(db, userId, sessId)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			utils.AbortWithStatus(c, 404)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	if !sess.Recorded {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	chunks, err := ssh.GetRecording(db, sess.Id)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if len(chunks) == 0 {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.Header("Content-Disposition",
+		"attachment; filename=\""+sess.Id.Hex()+".cast\"")
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Status(200)
+
+	for _, chunk := range chunks {
+		c.Writer.WriteString(chunk.Data)
+	}
+}
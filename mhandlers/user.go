@@ -13,6 +13,7 @@ import (
 	"github.com/pritunl/pritunl-zero/database"
 	"github.com/pritunl/pritunl-zero/demo"
 	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/revocation"
 	"github.com/pritunl/pritunl-zero/user"
 	"github.com/pritunl/pritunl-zero/utils"
 )
@@ -28,6 +29,7 @@ type userData struct {
 	GenerateSecret bool               `json:"generate_secret"`
 	Disabled       bool               `json:"disabled"`
 	ActiveUntil    time.Time          `json:"active_until"`
+	Machine        *user.Machine      `json:"machine"`
 }
 
 type usersData struct {
@@ -89,6 +91,7 @@ func userPut(c *gin.Context) {
 		return
 	}
 
+	wasDisabled := usr.Disabled
 	showSecret := false
 	if usr.Type != data.Type {
 		if data.Type == user.Api {
@@ -107,6 +110,7 @@ func userPut(c *gin.Context) {
 	usr.Permissions = data.Permissions
 	usr.Disabled = data.Disabled
 	usr.ActiveUntil = data.ActiveUntil
+	usr.Machine = data.Machine
 
 	if usr.Disabled {
 		usr.ActiveUntil = time.Time{}
@@ -127,6 +131,7 @@ func userPut(c *gin.Context) {
 		"permissions",
 		"disabled",
 		"active_until",
+		"machine",
 	)
 
 	if usr.Type == user.Local && data.Password != "" {
@@ -170,6 +175,14 @@ func userPut(c *gin.Context) {
 		return
 	}
 
+	if usr.Disabled && !wasDisabled {
+		err = revocation.RevokeUser(db, usr.Id, "User disabled")
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	event.PublishDispatch(db, "user.change")
 
 	if !showSecret {
@@ -201,6 +214,7 @@ func userPost(c *gin.Context) {
 		Permissions:   data.Permissions,
 		Disabled:      data.Disabled,
 		ActiveUntil:   data.ActiveUntil,
+		Machine:       data.Machine,
 	}
 
 	if usr.Disabled {
@@ -340,6 +354,14 @@ func usersDelete(c *gin.Context) {
 		return
 	}
 
+	for _, userId := range data {
+		err = revocation.RevokeUser(db, userId, "User deleted")
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	event.PublishDispatch(db, "user.change")
 
 	c.JSON(200, nil)
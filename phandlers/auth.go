@@ -83,8 +83,8 @@ func authSessionPost(c *gin.Context) {
 		return
 	}
 
-	devAuth, secProviderId, errAudit, errData, err := validator.ValidateProxy(
-		db, usr, false, srvc, c.Request)
+	devAuth, secProviderId, _, errAudit, errData, err :=
+		validator.ValidateProxy(db, usr, false, srvc, c.Request)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -276,7 +276,7 @@ func authSecondaryPost(c *gin.Context) {
 		return
 	}
 
-	deviceAuth, _, errAudit, errData, err := validator.ValidateProxy(
+	deviceAuth, _, stepUp, errAudit, errData, err := validator.ValidateProxy(
 		db, usr, false, srvc, c.Request)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -352,12 +352,35 @@ func authSecondaryPost(c *gin.Context) {
 
 	cook := cookie.NewProxy(srvc, c.Writer, c.Request)
 
-	_, err = cook.NewSession(db, c.Request, usr.Id, true, session.Proxy)
+	sess, err := cook.NewSession(db, c.Request, usr.Id, true, session.Proxy)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if stepUp {
+		err = sess.SetStepUp(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		err = audit.New(
+			db,
+			c.Request,
+			usr.Id,
+			audit.ProxyStepUp,
+			audit.Fields{
+				"method":      "secondary",
+				"provider_id": secd.ProviderId,
+			},
+		)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	redirectJson(c, c.Request.URL.Query().Get("redirect_url"))
 }
 
@@ -459,8 +482,8 @@ func authCallbackGet(c *gin.Context) {
 		return
 	}
 
-	devAuth, secProviderId, errAudit, errData, err := validator.ValidateProxy(
-		db, usr, false, srvc, c.Request)
+	devAuth, secProviderId, _, errAudit, errData, err :=
+		validator.ValidateProxy(db, usr, false, srvc, c.Request)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -689,7 +712,7 @@ func authU2fRegisterPost(c *gin.Context) {
 		return
 	}
 
-	_, _, errAudit, errData, err := validator.ValidateProxy(
+	_, _, stepUp, errAudit, errData, err := validator.ValidateProxy(
 		db, usr, false, srvc, c.Request)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -780,12 +803,34 @@ func authU2fRegisterPost(c *gin.Context) {
 
 	cook := cookie.NewProxy(srvc, c.Writer, c.Request)
 
-	_, err = cook.NewSession(db, c.Request, usr.Id, true, session.Proxy)
+	sess, err := cook.NewSession(db, c.Request, usr.Id, true, session.Proxy)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if stepUp {
+		err = sess.SetStepUp(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		err = audit.New(
+			db,
+			c.Request,
+			usr.Id,
+			audit.ProxyStepUp,
+			audit.Fields{
+				"method": "device_register",
+			},
+		)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	c.Status(200)
 }
 
@@ -879,7 +924,7 @@ func authU2fSignPost(c *gin.Context) {
 		return
 	}
 
-	_, secProviderId, errAudit, errData, err := validator.ValidateProxy(
+	_, secProviderId, stepUp, errAudit, errData, err := validator.ValidateProxy(
 		db, usr, false, srvc, c.Request)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -983,11 +1028,33 @@ func authU2fSignPost(c *gin.Context) {
 
 	cook := cookie.NewProxy(srvc, c.Writer, c.Request)
 
-	_, err = cook.NewSession(db, c.Request, usr.Id, true, session.Proxy)
+	sess, err := cook.NewSession(db, c.Request, usr.Id, true, session.Proxy)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
+	if stepUp {
+		err = sess.SetStepUp(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		err = audit.New(
+			db,
+			c.Request,
+			usr.Id,
+			audit.ProxyStepUp,
+			audit.Fields{
+				"method": "device",
+			},
+		)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	c.Status(200)
 }
@@ -1,5 +1,9 @@
 package policy
 
+import (
+	"time"
+)
+
 const (
 	Optional          = "optional"
 	Required          = "required"
@@ -9,4 +13,13 @@ const (
 	Location          = "location"
 	WhitelistNetworks = "whitelist_networks"
 	BlacklistNetworks = "blacklist_networks"
+	Schedule          = "schedule"
+	Expression        = "expression"
+	Enforce           = "enforce"
+	Monitor           = "monitor"
+
+	DefaultMonitorDays = 7
+	MaxMonitorDays     = 90
 )
+
+const monitorWindow = 1 * time.Hour
@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/device"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+type Target struct {
+	ServiceId    primitive.ObjectID
+	AuthorityIds []primitive.ObjectID
+}
+
+type Context struct {
+	Agent       *agent.Agent
+	User        *user.User
+	Time        time.Time
+	Target      *Target
+	db          *database.Database
+	deviceCount int64
+	deviceSync  bool
+}
+
+func (c *Context) getDeviceCount() (count int64, err error) {
+	if c.deviceSync || c.User == nil {
+		count = c.deviceCount
+		return
+	}
+
+	count, err = device.Count(c.db, c.User.Id)
+	if err != nil {
+		return
+	}
+
+	c.deviceCount = count
+	c.deviceSync = true
+
+	return
+}
+
+func (c *Context) variables(deviceCount bool) (
+	vars map[string]interface{}, err error) {
+
+	agnt := c.Agent
+	if agnt == nil {
+		agnt = &agent.Agent{}
+	}
+
+	now := c.Time.UTC()
+
+	vars = map[string]interface{}{
+		"agent.ip":               agnt.Ip,
+		"agent.isp":              agnt.Isp,
+		"agent.continent_code":   agnt.ContinentCode,
+		"agent.country_code":     agnt.CountryCode,
+		"agent.region_code":      agnt.RegionCode,
+		"agent.city":             agnt.City,
+		"agent.operating_system": agnt.OperatingSystem,
+		"agent.browser":          agnt.Browser,
+		"user.id":                "",
+		"user.username":          "",
+		"user.type":              "",
+		"user.roles":             []string{},
+		"time.hour":              int64(now.Hour()),
+		"time.minute":            int64(now.Minute()),
+		"time.weekday":           int64(now.Weekday()),
+		"time.unix":              now.Unix(),
+		"device.count":           int64(0),
+		"service.id":             "",
+		"authority.ids":          []string{},
+	}
+
+	if c.User != nil {
+		vars["user.id"] = c.User.Id.Hex()
+		vars["user.username"] = c.User.Username
+		vars["user.type"] = c.User.Type
+		if c.User.Roles != nil {
+			vars["user.roles"] = c.User.Roles
+		}
+	}
+
+	if c.Target != nil {
+		if !c.Target.ServiceId.IsZero() {
+			vars["service.id"] = c.Target.ServiceId.Hex()
+		}
+
+		authrIds := []string{}
+		for _, authrId := range c.Target.AuthorityIds {
+			authrIds = append(authrIds, authrId.Hex())
+		}
+		vars["authority.ids"] = authrIds
+	}
+
+	if deviceCount {
+		count, e := c.getDeviceCount()
+		if e != nil {
+			err = e
+			return
+		}
+		vars["device.count"] = count
+	}
+
+	return
+}
+
+func NewContext(db *database.Database, usr *user.User, agnt *agent.Agent,
+	now time.Time, target *Target) (ctx *Context) {
+
+	if agnt == nil {
+		agnt = &agent.Agent{}
+	}
+
+	ctx = &Context{
+		Agent:  agnt,
+		User:   usr,
+		Time:   now,
+		Target: target,
+		db:     db,
+	}
+
+	return
+}
@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/audit"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+type MonitorReport struct {
+	PolicyId primitive.ObjectID `json:"policy_id"`
+	Policy   string             `json:"policy"`
+	Rule     string             `json:"rule"`
+	RuleType string             `json:"rule_type"`
+	Error    string             `json:"error"`
+	Events   int                `json:"events"`
+	Users    int                `json:"users"`
+	users    map[primitive.ObjectID]bool
+}
+
+type monitorRecord struct {
+	Id        string    `bson:"_id"`
+	Timestamp time.Time `bson:"timestamp"`
+}
+
+func monitorFirst(db *database.Database, key string) (
+	first bool, err error) {
+
+	now := time.Now()
+	doc := &monitorRecord{
+		Id: fmt.Sprintf("%s/%d", key,
+			now.Truncate(monitorWindow).Unix()),
+		Timestamp: now,
+	}
+
+	coll := db.PolicyMonitors()
+
+	_, err = coll.InsertOne(db, doc)
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.DuplicateKeyError:
+			err = nil
+			break
+		}
+		return
+	}
+
+	first = true
+
+	return
+}
+
+func (p *Policy) IsMonitored(rule *Rule) bool {
+	return p.Mode == Monitor || rule.Mode == Monitor
+}
+
+func (p *Policy) monitor(db *database.Database, usr *user.User,
+	r *http.Request, key string, rule *Rule,
+	ruleErr *errortypes.ErrorData) (err error) {
+
+	first, err := monitorFirst(db, p.Id.Hex()+"/"+key+"/"+usr.Id.Hex())
+	if err != nil || !first {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"policy_id": p.Id.Hex(),
+		"policy":    p.Name,
+		"rule":      key,
+		"user_id":   usr.Id.Hex(),
+		"username":  usr.Username,
+		"error":     ruleErr.Error,
+	}).Info("policy: Monitored policy rule violation")
+
+	err = audit.New(
+		db,
+		r,
+		usr.Id,
+		audit.PolicyMonitor,
+		audit.Fields{
+			"policy_id": p.Id,
+			"policy":    p.Name,
+			"rule":      key,
+			"rule_type": rule.Type,
+			"disable":   rule.Disable,
+			"error":     ruleErr.Error,
+			"message":   ruleErr.Message,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "policy.monitor")
+
+	return
+}
+
+func GetMonitorReport(db *database.Database, days int) (
+	reports []*MonitorReport, err error) {
+
+	coll := db.Audits()
+	reports = []*MonitorReport{}
+	reportsMap := map[string]*MonitorReport{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"y": audit.PolicyMonitor,
+		"t": &bson.M{
+			"$gte": time.Now().AddDate(0, 0, -days),
+		},
+	}, &options.FindOptions{
+		Projection: &bson.D{
+			{"u", 1},
+			{"f", 1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		adt := &audit.Audit{}
+		err = cursor.Decode(adt)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		polcyId, _ := adt.Fields["policy_id"].(primitive.ObjectID)
+		polcyName, _ := adt.Fields["policy"].(string)
+		rule, _ := adt.Fields["rule"].(string)
+		ruleType, _ := adt.Fields["rule_type"].(string)
+		ruleErr, _ := adt.Fields["error"].(string)
+
+		reportKey := polcyId.Hex() + "/" + rule
+		report := reportsMap[reportKey]
+		if report == nil {
+			report = &MonitorReport{
+				PolicyId: polcyId,
+				Policy:   polcyName,
+				Rule:     rule,
+				RuleType: ruleType,
+				Error:    ruleErr,
+				users:    map[primitive.ObjectID]bool{},
+			}
+			reportsMap[reportKey] = report
+			reports = append(reports, report)
+		}
+
+		report.Events += 1
+		report.users[adt.User] = true
+		report.Users = len(report.users)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Users > reports[j].Users
+	})
+
+	return
+}
@@ -1,34 +1,31 @@
 package policy
 
 import (
-	"fmt"
-	"net"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/audit"
 	"github.com/pritunl/pritunl-zero/database"
 	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/expression"
 	"github.com/pritunl/pritunl-zero/node"
+	"github.com/pritunl/pritunl-zero/revocation"
 	"github.com/pritunl/pritunl-zero/settings"
 	"github.com/pritunl/pritunl-zero/subscription"
 	"github.com/pritunl/pritunl-zero/user"
 )
 
-type Rule struct {
-	Type    string   `bson:"type" json:"type"`
-	Disable bool     `bson:"disable" json:"disable"`
-	Values  []string `bson:"values" json:"values"`
-}
-
 type Policy struct {
 	Id                        primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
 	Name                      string               `bson:"name" json:"name"`
 	Disabled                  bool                 `bson:"disabled" json:"disabled"`
+	Mode                      string               `bson:"mode" json:"mode"`
 	Services                  []primitive.ObjectID `bson:"services" json:"services"`
 	Authorities               []primitive.ObjectID `bson:"authorities" json:"authorities"`
 	Roles                     []string             `bson:"roles" json:"roles"`
@@ -42,6 +39,8 @@ type Policy struct {
 	ProxyDeviceSecondary      bool                 `bson:"proxy_device_secondary" json:"proxy_device_secondary"`
 	AuthorityDeviceSecondary  bool                 `bson:"authority_device_secondary" json:"authority_device_secondary"`
 	AuthorityRequireSmartCard bool                 `bson:"authority_require_smart_card" json:"authority_require_smart_card"`
+	StepUpSecondary           primitive.ObjectID   `bson:"step_up_secondary,omitempty" json:"step_up_secondary"`
+	StepUpDeviceSecondary     bool                 `bson:"step_up_device_secondary" json:"step_up_device_secondary"`
 }
 
 func (p *Policy) Validate(db *database.Database) (
@@ -54,7 +53,50 @@ func (p *Policy) Validate(db *database.Database) (
 		p.Rules = map[string]*Rule{}
 	}
 
+	switch p.Mode {
+	case "":
+		p.Mode = Enforce
+		break
+	case Enforce, Monitor:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "invalid_policy_mode",
+			Message: "Policy mode is invalid",
+		}
+		return
+	}
+
 	for _, rule := range p.Rules {
+		if rule.Type != Schedule {
+			rule.Schedule = nil
+		}
+		if rule.Type != Expression {
+			rule.Expression = ""
+		}
+
+		if rule.StepUp && rule.Disable {
+			errData = &errortypes.ErrorData{
+				Error:   "invalid_rule_step_up",
+				Message: "Rule cannot both disable user and require step-up",
+			}
+			return
+		}
+
+		switch rule.Mode {
+		case "":
+			rule.Mode = Enforce
+			break
+		case Enforce, Monitor:
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "invalid_rule_mode",
+				Message: "Rule mode is invalid",
+			}
+			return
+		}
+
 		switch rule.Type {
 		case OperatingSystem:
 			break
@@ -74,6 +116,32 @@ func (p *Policy) Validate(db *database.Database) (
 			break
 		case BlacklistNetworks:
 			break
+		case Schedule:
+			if rule.Schedule == nil {
+				errData = &errortypes.ErrorData{
+					Error:   "schedule_missing",
+					Message: "Schedule rule is missing schedule",
+				}
+				return
+			}
+
+			errData = rule.Schedule.Validate()
+			if errData != nil {
+				return
+			}
+			break
+		case Expression:
+			rule.Expression = strings.TrimSpace(rule.Expression)
+
+			_, e := expression.Parse(rule.Expression)
+			if e != nil {
+				errData = &errortypes.ErrorData{
+					Error:   "expression_invalid",
+					Message: "Rule expression is invalid, " + e.Error(),
+				}
+				return
+			}
+			break
 		default:
 			errData = &errortypes.ErrorData{
 				Error:   "invalid_rule_type",
@@ -160,6 +228,23 @@ func (p *Policy) Validate(db *database.Database) (
 		p.AuthoritySecondary = primitive.NilObjectID
 	}
 
+	if !p.StepUpSecondary.IsZero() &&
+		settings.Auth.GetSecondaryProvider(p.StepUpSecondary) == nil {
+
+		p.StepUpSecondary = primitive.NilObjectID
+	}
+
+	if p.HasStepUp() && p.StepUpSecondary.IsZero() &&
+		!p.StepUpDeviceSecondary {
+
+		errData = &errortypes.ErrorData{
+			Error: "step_up_secondary_required",
+			Message: "Step-up rules require a step-up secondary " +
+				"authentication provider or device",
+		}
+		return
+	}
+
 	hasUserNode := false
 	nodes, err := node.GetAll(db)
 	if err != nil {
@@ -174,8 +259,8 @@ func (p *Policy) Validate(db *database.Database) (
 	}
 
 	if (p.AdminDeviceSecondary || p.UserDeviceSecondary ||
-		p.ProxyDeviceSecondary || p.AuthorityDeviceSecondary) &&
-		!hasUserNode {
+		p.ProxyDeviceSecondary || p.AuthorityDeviceSecondary ||
+		p.StepUpDeviceSecondary) && !hasUserNode {
 
 		errData = &errortypes.ErrorData{
 			Error: "user_node_unavailable",
@@ -189,7 +274,8 @@ func (p *Policy) Validate(db *database.Database) (
 }
 
 func (p *Policy) ValidateUser(db *database.Database, usr *user.User,
-	r *http.Request) (errData *errortypes.ErrorData, err error) {
+	r *http.Request, target *Target) (stepUp bool,
+	errData *errortypes.ErrorData, err error) {
 
 	if p.Disabled {
 		return
@@ -200,194 +286,65 @@ func (p *Policy) ValidateUser(db *database.Database, usr *user.User,
 		return
 	}
 
-	for _, rule := range p.Rules {
-		switch rule.Type {
-		case OperatingSystem:
-			match := false
-			for _, value := range rule.Values {
-				if value == agnt.OperatingSystem {
-					match = true
-					break
-				}
-			}
+	ctx := NewContext(db, usr, agnt, time.Now(), target)
 
-			if !match {
-				if rule.Disable {
-					errData = &errortypes.ErrorData{
-						Error:   "unauthorized",
-						Message: "Not authorized",
-					}
-
-					usr.Disabled = true
-					err = usr.CommitFields(db, set.NewSet("disabled"))
-					if err != nil {
-						return
-					}
-				} else {
-					errData = &errortypes.ErrorData{
-						Error:   "operating_system_policy",
-						Message: "Operating system not permitted",
-					}
-				}
-				return
-			}
-			break
-		case Browser:
-			match := false
-			for _, value := range rule.Values {
-				if value == agnt.Browser {
-					match = true
-					break
-				}
-			}
+	for key, rule := range p.Rules {
+		ruleErr := rule.Check(ctx)
+		if ruleErr == nil {
+			continue
+		}
 
-			if !match {
-				if rule.Disable {
-					errData = &errortypes.ErrorData{
-						Error:   "unauthorized",
-						Message: "Not authorized",
-					}
-
-					usr.Disabled = true
-					err = usr.CommitFields(db, set.NewSet("disabled"))
-					if err != nil {
-						return
-					}
-				} else {
-					errData = &errortypes.ErrorData{
-						Error:   "browser_policy",
-						Message: "Browser not permitted",
-					}
-				}
+		if p.IsMonitored(rule) {
+			err = p.monitor(db, usr, r, key, rule, ruleErr)
+			if err != nil {
 				return
 			}
-			break
-		case Location:
-			match := false
-			regionKey := fmt.Sprintf("%s_%s",
-				agnt.CountryCode, agnt.RegionCode)
-
-			for _, value := range rule.Values {
-				if value == agnt.CountryCode || value == regionKey {
-					match = true
-					break
-				}
-			}
+			continue
+		}
 
-			if !match {
-				if rule.Disable {
-					errData = &errortypes.ErrorData{
-						Error:   "unauthorized",
-						Message: "Not authorized",
-					}
-
-					usr.Disabled = true
-					err = usr.CommitFields(db, set.NewSet("disabled"))
-					if err != nil {
-						return
-					}
-				} else {
-					errData = &errortypes.ErrorData{
-						Error:   "location_policy",
-						Message: "Location not permitted",
-					}
-				}
+		if p.IsStepUp(rule, target) {
+			stepUp = true
+			continue
+		}
+
+		if rule.Type == Schedule {
+			err = audit.New(
+				db,
+				r,
+				usr.Id,
+				audit.SchedulePolicyDeny,
+				audit.Fields{
+					"policy_id": p.Id,
+					"policy":    p.Name,
+					"timezone":  rule.Schedule.Timezone,
+				},
+			)
+			if err != nil {
 				return
 			}
-			break
-		case WhitelistNetworks:
-			match := false
-			clientIp := net.ParseIP(agnt.Ip)
-
-			for _, value := range rule.Values {
-				_, network, e := net.ParseCIDR(value)
-				if e != nil {
-					err = &errortypes.ParseError{
-						errors.Wrap(e, "policy: Failed to parse network"),
-					}
-
-					logrus.WithFields(logrus.Fields{
-						"network": value,
-						"error":   err,
-					}).Error("policy: Invalid whitelist network")
-					err = nil
-					continue
-				}
+		}
 
-				if network.Contains(clientIp) {
-					match = true
-					break
-				}
+		if rule.Disable {
+			errData = &errortypes.ErrorData{
+				Error:   "unauthorized",
+				Message: "Not authorized",
 			}
 
-			if !match {
-				if rule.Disable {
-					errData = &errortypes.ErrorData{
-						Error:   "unauthorized",
-						Message: "Not authorized",
-					}
-
-					usr.Disabled = true
-					err = usr.CommitFields(db, set.NewSet("disabled"))
-					if err != nil {
-						return
-					}
-				} else {
-					errData = &errortypes.ErrorData{
-						Error:   "whitelist_networks_policy",
-						Message: "Network not permitted",
-					}
-				}
+			usr.Disabled = true
+			err = usr.CommitFields(db, set.NewSet("disabled"))
+			if err != nil {
 				return
 			}
-			break
-		case BlacklistNetworks:
-			match := false
-			clientIp := net.ParseIP(agnt.Ip)
-
-			for _, value := range rule.Values {
-				_, network, e := net.ParseCIDR(value)
-				if e != nil {
-					err = &errortypes.ParseError{
-						errors.Wrap(e, "policy: Failed to parse network"),
-					}
-
-					logrus.WithFields(logrus.Fields{
-						"network": value,
-						"error":   err,
-					}).Error("policy: Invalid blacklist network")
-					err = nil
-					continue
-				}
-
-				if network.Contains(clientIp) {
-					match = true
-					break
-				}
-			}
 
-			if match {
-				if rule.Disable {
-					errData = &errortypes.ErrorData{
-						Error:   "unauthorized",
-						Message: "Not authorized",
-					}
-
-					usr.Disabled = true
-					err = usr.CommitFields(db, set.NewSet("disabled"))
-					if err != nil {
-						return
-					}
-				} else {
-					errData = &errortypes.ErrorData{
-						Error:   "blacklist_networks_policy",
-						Message: "Network not permitted",
-					}
-				}
+			err = revocation.RevokeUser(
+				db, usr.Id, "User disabled by policy")
+			if err != nil {
 				return
 			}
-			break
+		} else {
+			errData = ruleErr
 		}
+		return
 	}
 
 	return
@@ -0,0 +1,238 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/expression"
+)
+
+type Rule struct {
+	Type       string                 `bson:"type" json:"type"`
+	Mode       string                 `bson:"mode" json:"mode"`
+	Disable    bool                   `bson:"disable" json:"disable"`
+	StepUp     bool                   `bson:"step_up" json:"step_up"`
+	Values     []string               `bson:"values" json:"values"`
+	Schedule   *TimeWindow            `bson:"schedule,omitempty" json:"schedule"`
+	Expression string                 `bson:"expression,omitempty" json:"expression"`
+	expr       *expression.Expression `bson:"-" json:"-"`
+}
+
+type RuleResult struct {
+	Key       string `json:"key"`
+	Type      string `json:"type"`
+	Passed    bool   `json:"passed"`
+	Monitored bool   `json:"monitored"`
+	Disable   bool   `json:"disable"`
+	StepUp    bool   `json:"step_up"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+}
+
+func matchNetworks(values []string, ip string) bool {
+	clientIp := net.ParseIP(ip)
+
+	for _, value := range values {
+		_, network, e := net.ParseCIDR(value)
+		if e != nil {
+			err := &errortypes.ParseError{
+				errors.Wrap(e, "policy: Failed to parse network"),
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"network": value,
+				"error":   err,
+			}).Error("policy: Invalid policy network")
+			continue
+		}
+
+		if network.Contains(clientIp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) getExpression() (expr *expression.Expression, err error) {
+	if r.expr != nil {
+		expr = r.expr
+		return
+	}
+
+	expr, err = expression.Parse(r.Expression)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "policy: Failed to parse rule expression"),
+		}
+		return
+	}
+	r.expr = expr
+
+	return
+}
+
+func (r *Rule) checkExpression(ctx *Context) (
+	errData *errortypes.ErrorData) {
+
+	expr, err := r.getExpression()
+	if err == nil {
+		vars, e := ctx.variables(expr.Uses("device.count"))
+		if e != nil {
+			err = e
+		} else {
+			match, e := expr.Eval(vars)
+			if e != nil {
+				err = e
+			} else if match {
+				return
+			}
+		}
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"expression": r.Expression,
+			"error":      err,
+		}).Error("policy: Failed to evaluate rule expression")
+	}
+
+	errData = &errortypes.ErrorData{
+		Error:   "expression_policy",
+		Message: "Access not permitted by policy",
+	}
+	return
+}
+
+func (r *Rule) Check(ctx *Context) (errData *errortypes.ErrorData) {
+	agnt := ctx.Agent
+
+	switch r.Type {
+	case OperatingSystem:
+		for _, value := range r.Values {
+			if value == agnt.OperatingSystem {
+				return
+			}
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "operating_system_policy",
+			Message: "Operating system not permitted",
+		}
+		break
+	case Browser:
+		for _, value := range r.Values {
+			if value == agnt.Browser {
+				return
+			}
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "browser_policy",
+			Message: "Browser not permitted",
+		}
+		break
+	case Location:
+		regionKey := fmt.Sprintf("%s_%s",
+			agnt.CountryCode, agnt.RegionCode)
+
+		for _, value := range r.Values {
+			if value == agnt.CountryCode || value == regionKey {
+				return
+			}
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "location_policy",
+			Message: "Location not permitted",
+		}
+		break
+	case WhitelistNetworks:
+		if matchNetworks(r.Values, agnt.Ip) {
+			return
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "whitelist_networks_policy",
+			Message: "Network not permitted",
+		}
+		break
+	case BlacklistNetworks:
+		if !matchNetworks(r.Values, agnt.Ip) {
+			return
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "blacklist_networks_policy",
+			Message: "Network not permitted",
+		}
+		break
+	case Schedule:
+		if r.Schedule == nil || r.Schedule.Allowed(ctx.Time) {
+			return
+		}
+
+		errData = &errortypes.ErrorData{
+			Error:   "schedule_policy",
+			Message: "Access not permitted at this time",
+		}
+		break
+	case Expression:
+		errData = r.checkExpression(ctx)
+		break
+	}
+
+	return
+}
+
+func (p *Policy) Evaluate(ctx *Context) (results []*RuleResult,
+	stepUp bool, errData *errortypes.ErrorData) {
+
+	results = []*RuleResult{}
+
+	if p.Disabled {
+		return
+	}
+
+	keys := []string{}
+	for key := range p.Rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		rule := p.Rules[key]
+
+		result := &RuleResult{
+			Key:       key,
+			Type:      rule.Type,
+			Passed:    true,
+			Monitored: p.IsMonitored(rule),
+			Disable:   rule.Disable,
+			StepUp:    p.IsStepUp(rule, ctx.Target),
+		}
+
+		ruleErr := rule.Check(ctx)
+		if ruleErr != nil {
+			result.Passed = false
+			result.Error = ruleErr.Error
+			result.Message = ruleErr.Message
+
+			if !result.Monitored {
+				if result.StepUp {
+					stepUp = true
+				} else if errData == nil {
+					errData = ruleErr
+				}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return
+}
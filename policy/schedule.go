@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+type TimeWindow struct {
+	Timezone string   `bson:"timezone" json:"timezone"`
+	Weekdays []int    `bson:"weekdays" json:"weekdays"`
+	Start    string   `bson:"start" json:"start"`
+	End      string   `bson:"end" json:"end"`
+	Holidays []string `bson:"holidays" json:"holidays"`
+}
+
+func parseClock(val string) (mins int, ok bool) {
+	if val == "" {
+		ok = true
+		return
+	}
+
+	t, err := time.Parse("15:04", val)
+	if err != nil {
+		return
+	}
+
+	mins = t.Hour()*60 + t.Minute()
+	ok = true
+	return
+}
+
+func (s *TimeWindow) hasWeekday(day time.Weekday) bool {
+	for _, weekday := range s.Weekdays {
+		if weekday == int(day) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TimeWindow) isHoliday(t time.Time) bool {
+	date := t.Format("2006-01-02")
+	for _, holiday := range s.Holidays {
+		if holiday == date {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TimeWindow) Validate() (errData *errortypes.ErrorData) {
+	s.Timezone = strings.TrimSpace(s.Timezone)
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+
+	_, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "schedule_timezone_invalid",
+			Message: "Schedule time zone is not valid",
+		}
+		return
+	}
+
+	if len(s.Weekdays) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "schedule_weekdays_missing",
+			Message: "Schedule must allow at least one weekday",
+		}
+		return
+	}
+
+	for _, weekday := range s.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			errData = &errortypes.ErrorData{
+				Error:   "schedule_weekdays_invalid",
+				Message: "Schedule weekdays must be between 0 and 6",
+			}
+			return
+		}
+	}
+
+	s.Start = strings.TrimSpace(s.Start)
+	s.End = strings.TrimSpace(s.End)
+
+	_, startOk := parseClock(s.Start)
+	_, endOk := parseClock(s.End)
+	if !startOk || !endOk {
+		errData = &errortypes.ErrorData{
+			Error:   "schedule_hours_invalid",
+			Message: "Schedule hours must be in HH:MM format",
+		}
+		return
+	}
+
+	holidays := []string{}
+	for _, holiday := range s.Holidays {
+		holiday = strings.TrimSpace(holiday)
+		if holiday == "" {
+			continue
+		}
+
+		_, err = time.Parse("2006-01-02", holiday)
+		if err != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "schedule_holiday_invalid",
+				Message: "Schedule holidays must be in YYYY-MM-DD format",
+			}
+			return
+		}
+
+		holidays = append(holidays, holiday)
+	}
+	s.Holidays = holidays
+
+	return
+}
+
+func (s *TimeWindow) hasDay(t time.Time) bool {
+	return s.hasWeekday(t.Weekday()) && !s.isHoliday(t)
+}
+
+func (s *TimeWindow) Allowed(now time.Time) bool {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+
+	t := now.In(loc)
+
+	start, _ := parseClock(s.Start)
+	end, _ := parseClock(s.End)
+	mins := t.Hour()*60 + t.Minute()
+
+	if start == end {
+		return s.hasDay(t)
+	} else if start < end {
+		return s.hasDay(t) && mins >= start && mins < end
+	}
+
+	if mins >= start {
+		return s.hasDay(t)
+	} else if mins < end {
+		return s.hasDay(t.AddDate(0, 0, -1))
+	}
+
+	return false
+}
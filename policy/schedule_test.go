@@ -0,0 +1,259 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleAllowed(t *testing.T) {
+	weekdays := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name     string
+		schedule *TimeWindow
+		now      string
+		allowed  bool
+	}{
+		{
+			"day_inside",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00", nil},
+			"2024-03-05T17:00:00Z",
+			true,
+		},
+		{
+			"day_start",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00", nil},
+			"2024-03-05T14:00:00Z",
+			true,
+		},
+		{
+			"day_end",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00", nil},
+			"2024-03-05T22:00:00Z",
+			false,
+		},
+		{
+			"day_before",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00", nil},
+			"2024-03-05T13:59:00Z",
+			false,
+		},
+		{
+			"day_weekend",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00", nil},
+			"2024-03-09T15:00:00Z",
+			false,
+		},
+		{
+			"day_utc_next_day",
+			&TimeWindow{"America/Los_Angeles", weekdays, "09:00", "17:00",
+				nil},
+			"2024-03-09T00:30:00Z",
+			true,
+		},
+		{
+			"day_dst",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00", nil},
+			"2024-03-11T13:00:00Z",
+			true,
+		},
+		{
+			"day_tokyo_weekday",
+			&TimeWindow{"Asia/Tokyo", weekdays, "09:00", "17:00", nil},
+			"2024-03-04T01:00:00Z",
+			true,
+		},
+		{
+			"day_tokyo_weekend",
+			&TimeWindow{"Asia/Tokyo", weekdays, "09:00", "17:00", nil},
+			"2024-03-03T01:00:00Z",
+			false,
+		},
+		{
+			"overnight_evening",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00", nil},
+			"2024-03-08T22:30:00Z",
+			true,
+		},
+		{
+			"overnight_morning",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00", nil},
+			"2024-03-09T04:30:00Z",
+			true,
+		},
+		{
+			"overnight_end",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00", nil},
+			"2024-03-09T05:00:00Z",
+			false,
+		},
+		{
+			"overnight_gap",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00", nil},
+			"2024-03-08T12:00:00Z",
+			false,
+		},
+		{
+			"overnight_previous_day",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00", nil},
+			"2024-03-08T02:00:00Z",
+			false,
+		},
+		{
+			"overnight_next_evening",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00", nil},
+			"2024-03-09T22:30:00Z",
+			false,
+		},
+		{
+			"overnight_utc_crossing",
+			&TimeWindow{"Asia/Kolkata", []int{0}, "23:00", "02:00", nil},
+			"2024-03-10T19:00:00Z",
+			true,
+		},
+		{
+			"all_day",
+			&TimeWindow{"UTC", []int{6}, "", "", nil},
+			"2024-03-09T23:59:00Z",
+			true,
+		},
+		{
+			"all_day_other",
+			&TimeWindow{"UTC", []int{6}, "", "", nil},
+			"2024-03-10T00:00:00Z",
+			false,
+		},
+		{
+			"holiday",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00",
+				[]string{"2024-12-25"}},
+			"2024-12-25T15:00:00Z",
+			false,
+		},
+		{
+			"holiday_other",
+			&TimeWindow{"America/New_York", weekdays, "09:00", "17:00",
+				[]string{"2024-12-25"}},
+			"2024-12-24T15:00:00Z",
+			true,
+		},
+		{
+			"holiday_local_date",
+			&TimeWindow{"Asia/Tokyo", []int{0, 1, 2, 3, 4, 5, 6}, "", "",
+				[]string{"2024-12-25"}},
+			"2024-12-24T16:00:00Z",
+			false,
+		},
+		{
+			"holiday_local_date_before",
+			&TimeWindow{"America/Los_Angeles", []int{0, 1, 2, 3, 4, 5, 6},
+				"", "", []string{"2024-12-25"}},
+			"2024-12-25T07:00:00Z",
+			true,
+		},
+		{
+			"holiday_overnight",
+			&TimeWindow{"Europe/Berlin", weekdays, "22:00", "06:00",
+				[]string{"2024-12-25"}},
+			"2024-12-25T01:00:00Z",
+			true,
+		},
+		{
+			"holiday_overnight_start",
+			&TimeWindow{"Europe/Berlin", weekdays, "22:00", "06:00",
+				[]string{"2024-12-25"}},
+			"2024-12-25T22:00:00Z",
+			false,
+		},
+		{
+			"holiday_overnight_morning",
+			&TimeWindow{"Europe/Berlin", weekdays, "22:00", "06:00",
+				[]string{"2024-12-25"}},
+			"2024-12-26T01:00:00Z",
+			false,
+		},
+		{
+			"holiday_overnight_friday",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00",
+				[]string{"2024-03-08"}},
+			"2024-03-09T01:00:00Z",
+			false,
+		},
+		{
+			"holiday_overnight_saturday",
+			&TimeWindow{"Europe/Berlin", []int{5}, "22:00", "06:00",
+				[]string{"2024-03-09"}},
+			"2024-03-09T01:00:00Z",
+			true,
+		},
+		{
+			"timezone_invalid",
+			&TimeWindow{"Invalid/Zone", weekdays, "", "", nil},
+			"2024-03-05T14:00:00Z",
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		now, err := time.Parse(time.RFC3339, test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		allowed := test.schedule.Allowed(now)
+		if allowed != test.allowed {
+			t.Errorf("Wrong schedule result %s: %t", test.name, allowed)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		schedule *TimeWindow
+		error    string
+	}{
+		{&TimeWindow{"", []int{1}, "09:00", "17:00", nil}, ""},
+		{&TimeWindow{" Europe/Berlin ", []int{0, 6}, "", "", nil}, ""},
+		{&TimeWindow{"UTC", []int{1}, "22:00", "06:00",
+			[]string{" 2024-12-25 ", ""}}, ""},
+		{&TimeWindow{"Invalid/Zone", []int{1}, "", "", nil},
+			"schedule_timezone_invalid"},
+		{&TimeWindow{"UTC", []int{}, "", "", nil},
+			"schedule_weekdays_missing"},
+		{&TimeWindow{"UTC", []int{1, 7}, "", "", nil},
+			"schedule_weekdays_invalid"},
+		{&TimeWindow{"UTC", []int{-1}, "", "", nil},
+			"schedule_weekdays_invalid"},
+		{&TimeWindow{"UTC", []int{1}, "9am", "17:00", nil},
+			"schedule_hours_invalid"},
+		{&TimeWindow{"UTC", []int{1}, "09:00", "24:00", nil},
+			"schedule_hours_invalid"},
+		{&TimeWindow{"UTC", []int{1}, "", "", []string{"12/25/2024"}},
+			"schedule_holiday_invalid"},
+	}
+
+	for _, test := range tests {
+		errData := test.schedule.Validate()
+
+		errStr := ""
+		if errData != nil {
+			errStr = errData.Error
+		}
+
+		if errStr != test.error {
+			t.Errorf("Wrong schedule validate %v: %s",
+				test.schedule, errStr)
+		}
+	}
+
+	schedule := &TimeWindow{"", []int{1}, "", "",
+		[]string{" 2024-12-25 ", ""}}
+	schedule.Validate()
+
+	if schedule.Timezone != "UTC" {
+		t.Errorf("Wrong default timezone: %s", schedule.Timezone)
+	}
+	if len(schedule.Holidays) != 1 || schedule.Holidays[0] != "2024-12-25" {
+		t.Errorf("Wrong holidays: %v", schedule.Holidays)
+	}
+}
@@ -0,0 +1,15 @@
+package policy
+
+// Step-up rules only apply to service access, other targets deny.
+func (p *Policy) HasStepUp() bool {
+	for _, rule := range p.Rules {
+		if rule.StepUp {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) IsStepUp(rule *Rule, target *Target) bool {
+	return rule.StepUp && target != nil && !target.ServiceId.IsZero()
+}
@@ -192,13 +192,27 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) bool {
 		return false
 	}
 
-	_, _, errAudit, errData, err := validator.ValidateProxy(
+	_, _, stepUp, errAudit, errData, err := validator.ValidateProxy(
 		db, usr, authr.IsApi(), host.Service, r)
 	if err != nil {
 		WriteError(w, r, 500, err)
 		return true
 	}
 
+	if errData == nil && stepUp {
+		sess := authr.GetSession()
+		if sess == nil || !sess.StepUp {
+			errAudit = audit.Fields{
+				"error":   "step_up_required",
+				"message": "Session requires step-up authentication",
+			}
+			errData = &errortypes.ErrorData{
+				Error:   "step_up_required",
+				Message: "Step-up authentication required",
+			}
+		}
+	}
+
 	if errData != nil {
 		err = authr.Clear(db, w, r)
 		if err != nil {
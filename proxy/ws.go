@@ -133,8 +133,9 @@ func (w *webSocketConn) Run(db *database.Database) {
 							return
 						}
 
-						_, _, _, errData, err := validator.ValidateProxy(
-							db, usr, w.authr.IsApi(), srvc, w.r)
+						_, _, stepUp, _, errData, err :=
+							validator.ValidateProxy(
+								db, usr, w.authr.IsApi(), srvc, w.r)
 						if err != nil {
 							logrus.WithFields(logrus.Fields{
 								"error": err,
@@ -147,6 +148,11 @@ func (w *webSocketConn) Run(db *database.Database) {
 							w.Close()
 							return
 						}
+
+						if stepUp && (sess == nil || !sess.StepUp) {
+							w.Close()
+							return
+						}
 					}
 				}
 
@@ -0,0 +1,18 @@
+package revocation
+
+const (
+	Serial    = "serial"
+	KeyId     = "key_id"
+	PublicKey = "public_key"
+)
+
+const (
+	krlMagic   = 0x5353484b524c0a00
+	krlVersion = 1
+
+	krlSectionCertificates = 1
+	krlSectionExplicitKey  = 2
+
+	krlSectionCertSerialList = 0x20
+	krlSectionCertKeyId      = 0x23
+)
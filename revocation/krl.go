@@ -0,0 +1,163 @@
+package revocation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pritunl/pritunl-zero/authority"
+	"golang.org/x/crypto/ssh"
+)
+
+func writeUint32(buf *bytes.Buffer, val uint32) {
+	binary.Write(buf, binary.BigEndian, val)
+}
+
+func writeUint64(buf *bytes.Buffer, val uint64) {
+	binary.Write(buf, binary.BigEndian, val)
+}
+
+func writeString(buf *bytes.Buffer, val []byte) {
+	writeUint32(buf, uint32(len(val)))
+	buf.Write(val)
+}
+
+func writeSection(buf *bytes.Buffer, typ byte, data []byte) {
+	buf.WriteByte(typ)
+	writeString(buf, data)
+}
+
+func parsePublicKey(publicKey string) (blob []byte, ok bool) {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return
+	}
+
+	blob = pubKey.Marshal()
+	ok = true
+
+	return
+}
+
+func certificatesSection(caKey []byte, serials []uint64,
+	keyIds []string) []byte {
+
+	buf := &bytes.Buffer{}
+
+	writeString(buf, caKey)
+	writeString(buf, nil)
+
+	if len(serials) > 0 {
+		data := &bytes.Buffer{}
+		for _, serial := range serials {
+			writeUint64(data, serial)
+		}
+		writeSection(buf, krlSectionCertSerialList, data.Bytes())
+	}
+
+	if len(keyIds) > 0 {
+		data := &bytes.Buffer{}
+		for _, keyId := range keyIds {
+			writeString(data, []byte(keyId))
+		}
+		writeSection(buf, krlSectionCertKeyId, data.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+func Krl(authrs []*authority.Authority, revokes []*Revocation) (
+	data []byte) {
+
+	serialsMap := map[string]map[uint64]bool{}
+	keyIdsMap := map[string]bool{}
+	publicKeysMap := map[string][]byte{}
+
+	for _, revoke := range revokes {
+		switch revoke.Type {
+		case Serial:
+			serial, e := strconv.ParseUint(revoke.Serial, 10, 64)
+			if e != nil || serial == 0 {
+				continue
+			}
+
+			authrId := revoke.AuthorityId.Hex()
+			if serialsMap[authrId] == nil {
+				serialsMap[authrId] = map[uint64]bool{}
+			}
+			serialsMap[authrId][serial] = true
+			break
+		case KeyId:
+			keyIdsMap[revoke.KeyId] = true
+			break
+		case PublicKey:
+			blob, ok := parsePublicKey(revoke.PublicKey)
+			if !ok {
+				continue
+			}
+			publicKeysMap[string(blob)] = blob
+			break
+		}
+	}
+
+	keyIds := []string{}
+	for keyId := range keyIdsMap {
+		keyIds = append(keyIds, keyId)
+	}
+	sort.Strings(keyIds)
+
+	now := uint64(time.Now().Unix())
+	buf := &bytes.Buffer{}
+
+	writeUint64(buf, krlMagic)
+	writeUint32(buf, krlVersion)
+	writeUint64(buf, now)
+	writeUint64(buf, now)
+	writeUint64(buf, 0)
+	writeString(buf, nil)
+	writeString(buf, []byte("pritunl-zero"))
+
+	for _, authr := range authrs {
+		serials := []uint64{}
+		for serial := range serialsMap[authr.Id.Hex()] {
+			serials = append(serials, serial)
+		}
+		sort.Slice(serials, func(i, j int) bool {
+			return serials[i] < serials[j]
+		})
+
+		if len(serials) == 0 && len(keyIds) == 0 {
+			continue
+		}
+
+		for _, publicKey := range authr.GetPublicKeys() {
+			caKey, ok := parsePublicKey(publicKey)
+			if !ok {
+				continue
+			}
+
+			writeSection(buf, krlSectionCertificates,
+				certificatesSection(caKey, serials, keyIds))
+		}
+	}
+
+	if len(publicKeysMap) > 0 {
+		publicKeys := []string{}
+		for key := range publicKeysMap {
+			publicKeys = append(publicKeys, key)
+		}
+		sort.Strings(publicKeys)
+
+		keysData := &bytes.Buffer{}
+		for _, key := range publicKeys {
+			writeString(keysData, publicKeysMap[key])
+		}
+		writeSection(buf, krlSectionExplicitKey, keysData.Bytes())
+	}
+
+	data = buf.Bytes()
+
+	return
+}
@@ -0,0 +1,341 @@
+package revocation
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/authority"
+	"golang.org/x/crypto/ssh"
+)
+
+type testCa struct {
+	authr  *authority.Authority
+	signer ssh.Signer
+}
+
+func newTestCa(t *testing.T) (ca *testCa) {
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca = &testCa{
+		authr: &authority.Authority{
+			Id: primitive.NewObjectID(),
+			PublicKey: strings.TrimSpace(
+				string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+		},
+		signer: signer,
+	}
+
+	return
+}
+
+func newTestKey(t *testing.T) (pubKey ssh.PublicKey) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, err = ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func (c *testCa) sign(t *testing.T, pubKey ssh.PublicKey,
+	serial uint64, keyId string) (cert *ssh.Certificate) {
+
+	cert = &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          serial,
+		CertType:        ssh.UserCert,
+		KeyId:           keyId,
+		ValidPrincipals: []string{"test"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+
+	err := cert.SignCert(rand.Reader, c.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+type krlReader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (r *krlReader) byte() (val byte) {
+	if len(r.data) < 1 {
+		r.t.Fatal("Unexpected end of krl")
+	}
+	val = r.data[0]
+	r.data = r.data[1:]
+	return
+}
+
+func (r *krlReader) uint32() (val uint32) {
+	if len(r.data) < 4 {
+		r.t.Fatal("Unexpected end of krl")
+	}
+	val = binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return
+}
+
+func (r *krlReader) uint64() (val uint64) {
+	if len(r.data) < 8 {
+		r.t.Fatal("Unexpected end of krl")
+	}
+	val = binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return
+}
+
+func (r *krlReader) string() (val []byte) {
+	n := int(r.uint32())
+	if len(r.data) < n {
+		r.t.Fatal("Unexpected end of krl")
+	}
+	val = r.data[:n]
+	r.data = r.data[n:]
+	return
+}
+
+func TestKrlLayout(t *testing.T) {
+	ca := newTestCa(t)
+	pubKey := newTestKey(t)
+
+	revokes := []*Revocation{
+		{Type: Serial, AuthorityId: ca.authr.Id, Serial: "30"},
+		{Type: Serial, AuthorityId: ca.authr.Id, Serial: "7"},
+		{Type: Serial, AuthorityId: ca.authr.Id, Serial: "invalid"},
+		{Type: Serial, AuthorityId: primitive.NewObjectID(), Serial: "9"},
+		{Type: KeyId, KeyId: "user-b"},
+		{Type: KeyId, KeyId: "user-a"},
+		{Type: PublicKey, PublicKey: string(
+			ssh.MarshalAuthorizedKey(pubKey))},
+		{Type: PublicKey, PublicKey: "invalid"},
+	}
+
+	r := &krlReader{
+		t:    t,
+		data: Krl([]*authority.Authority{ca.authr}, revokes),
+	}
+
+	if magic := r.uint64(); magic != krlMagic {
+		t.Errorf("Wrong krl magic: %x", magic)
+	}
+	if version := r.uint32(); version != krlVersion {
+		t.Errorf("Wrong krl format version: %d", version)
+	}
+
+	krlVer := r.uint64()
+	generated := r.uint64()
+	if krlVer == 0 || krlVer != generated {
+		t.Errorf("Wrong krl version %d generated %d", krlVer, generated)
+	}
+
+	if flags := r.uint64(); flags != 0 {
+		t.Errorf("Wrong krl flags: %d", flags)
+	}
+	if reserved := r.string(); len(reserved) != 0 {
+		t.Errorf("Wrong krl reserved: %x", reserved)
+	}
+	if comment := r.string(); string(comment) != "pritunl-zero" {
+		t.Errorf("Wrong krl comment: %s", comment)
+	}
+
+	if typ := r.byte(); typ != krlSectionCertificates {
+		t.Fatalf("Wrong krl section: %d", typ)
+	}
+	certs := &krlReader{
+		t:    t,
+		data: r.string(),
+	}
+
+	if caKey := certs.string(); !bytes.Equal(
+		caKey, ca.signer.PublicKey().Marshal()) {
+
+		t.Errorf("Wrong krl ca key")
+	}
+	if reserved := certs.string(); len(reserved) != 0 {
+		t.Errorf("Wrong krl certificates reserved: %x", reserved)
+	}
+
+	if typ := certs.byte(); typ != krlSectionCertSerialList {
+		t.Fatalf("Wrong krl certificates section: %d", typ)
+	}
+	serials := &krlReader{
+		t:    t,
+		data: certs.string(),
+	}
+	for _, serial := range []uint64{7, 30} {
+		if val := serials.uint64(); val != serial {
+			t.Errorf("Wrong krl serial: %d", val)
+		}
+	}
+	if len(serials.data) != 0 {
+		t.Errorf("Unexpected krl serials")
+	}
+
+	if typ := certs.byte(); typ != krlSectionCertKeyId {
+		t.Fatalf("Wrong krl certificates section: %d", typ)
+	}
+	keyIds := &krlReader{
+		t:    t,
+		data: certs.string(),
+	}
+	for _, keyId := range []string{"user-a", "user-b"} {
+		if val := keyIds.string(); string(val) != keyId {
+			t.Errorf("Wrong krl key id: %s", val)
+		}
+	}
+	if len(keyIds.data) != 0 || len(certs.data) != 0 {
+		t.Errorf("Unexpected krl certificates data")
+	}
+
+	if typ := r.byte(); typ != krlSectionExplicitKey {
+		t.Fatalf("Wrong krl section: %d", typ)
+	}
+	keys := &krlReader{
+		t:    t,
+		data: r.string(),
+	}
+	if key := keys.string(); !bytes.Equal(key, pubKey.Marshal()) {
+		t.Errorf("Wrong krl explicit key")
+	}
+	if len(keys.data) != 0 || len(r.data) != 0 {
+		t.Errorf("Unexpected krl data")
+	}
+}
+
+func TestKrlEmpty(t *testing.T) {
+	ca := newTestCa(t)
+
+	data := Krl([]*authority.Authority{ca.authr}, []*Revocation{})
+	if len(data) != 44+len("pritunl-zero") {
+		t.Errorf("Wrong empty krl length: %d", len(data))
+	}
+}
+
+func TestKrlSshKeygen(t *testing.T) {
+	sshKeygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+
+	ca := newTestCa(t)
+	otherCa := newTestCa(t)
+	revokedKey := newTestKey(t)
+
+	revokes := []*Revocation{
+		{Type: Serial, AuthorityId: ca.authr.Id, Serial: "100"},
+		{Type: Serial, AuthorityId: otherCa.authr.Id, Serial: "200"},
+		{Type: KeyId, KeyId: "revoked-id"},
+		{Type: PublicKey, PublicKey: string(
+			ssh.MarshalAuthorizedKey(revokedKey))},
+	}
+
+	dir, err := ioutil.TempDir("", "krl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	krlPath := filepath.Join(dir, "krl")
+	err = ioutil.WriteFile(krlPath,
+		Krl([]*authority.Authority{ca.authr}, revokes), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		revoked bool
+	}{
+		{
+			"serial",
+			ssh.MarshalAuthorizedKey(
+				ca.sign(t, newTestKey(t), 100, "valid-id")),
+			true,
+		},
+		{
+			"other_serial",
+			ssh.MarshalAuthorizedKey(
+				ca.sign(t, newTestKey(t), 101, "valid-id")),
+			false,
+		},
+		{
+			"other_authority_serial",
+			ssh.MarshalAuthorizedKey(
+				otherCa.sign(t, newTestKey(t), 200, "valid-id")),
+			false,
+		},
+		{
+			"key_id",
+			ssh.MarshalAuthorizedKey(
+				ca.sign(t, newTestKey(t), 102, "revoked-id")),
+			true,
+		},
+		{
+			"public_key",
+			ssh.MarshalAuthorizedKey(revokedKey),
+			true,
+		},
+		{
+			"public_key_cert",
+			ssh.MarshalAuthorizedKey(
+				otherCa.sign(t, revokedKey, 300, "valid-id")),
+			true,
+		},
+		{
+			"valid_key",
+			ssh.MarshalAuthorizedKey(newTestKey(t)),
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		keyPath := filepath.Join(dir, "key"+strconv.Itoa(i)+".pub")
+		err = ioutil.WriteFile(keyPath, test.data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output, _ := exec.Command(
+			sshKeygen, "-Q", "-f", krlPath, keyPath).CombinedOutput()
+
+		result := strings.TrimSpace(string(output))
+		revoked := strings.HasSuffix(result, ": REVOKED")
+		if !revoked && !strings.HasSuffix(result, ": ok") {
+			t.Errorf("Failed to query krl %s: %s", test.name, output)
+			continue
+		}
+
+		if revoked != test.revoked {
+			t.Errorf("Wrong krl result %s: %s", test.name, output)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package revocation
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"golang.org/x/crypto/ssh"
+)
+
+type Revocation struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type        string             `bson:"type" json:"type"`
+	AuthorityId primitive.ObjectID `bson:"authority_id,omitempty" json:"authority_id"`
+	UserId      primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"`
+	Serial      string             `bson:"serial" json:"serial"`
+	KeyId       string             `bson:"key_id" json:"key_id"`
+	PublicKey   string             `bson:"public_key" json:"public_key"`
+	Comment     string             `bson:"comment" json:"comment"`
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
+	Expires     time.Time          `bson:"expires" json:"expires"`
+}
+
+func (r *Revocation) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now()
+	}
+
+	r.Comment = strings.TrimSpace(r.Comment)
+
+	switch r.Type {
+	case Serial:
+		if r.AuthorityId.IsZero() {
+			errData = &errortypes.ErrorData{
+				Error:   "authority_missing",
+				Message: "Serial revocation requires an authority",
+			}
+			return
+		}
+
+		r.Serial = strings.TrimSpace(r.Serial)
+		serial, e := strconv.ParseUint(r.Serial, 10, 64)
+		if e != nil || serial == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "serial_invalid",
+				Message: "Certificate serial is invalid",
+			}
+			return
+		}
+
+		r.KeyId = ""
+		r.PublicKey = ""
+		break
+	case KeyId:
+		r.KeyId = strings.TrimSpace(r.KeyId)
+		if r.KeyId == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "key_id_invalid",
+				Message: "Certificate key ID is invalid",
+			}
+			return
+		}
+
+		r.AuthorityId = primitive.NilObjectID
+		r.Serial = ""
+		r.PublicKey = ""
+		break
+	case PublicKey:
+		pubKey, _, _, _, e := ssh.ParseAuthorizedKey(
+			[]byte(strings.TrimSpace(r.PublicKey)))
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "public_key_invalid",
+				Message: "Public key is invalid",
+			}
+			return
+		}
+
+		r.PublicKey = strings.TrimSpace(
+			string(ssh.MarshalAuthorizedKey(pubKey)))
+		r.AuthorityId = primitive.NilObjectID
+		r.Serial = ""
+		r.KeyId = ""
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "type_invalid",
+			Message: "Revocation type is invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (r *Revocation) Commit(db *database.Database) (err error) {
+	coll := db.SshRevocations()
+
+	err = coll.Commit(r.Id, r)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *Revocation) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.SshRevocations()
+
+	err = coll.CommitFields(r.Id, r, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *Revocation) Insert(db *database.Database) (err error) {
+	coll := db.SshRevocations()
+
+	if r.Id.IsZero() {
+		r.Id = primitive.NewObjectID()
+	}
+
+	_, err = coll.InsertOne(db, r)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (r *Revocation) Upsert(db *database.Database) (err error) {
+	coll := db.SshRevocations()
+
+	query := &bson.M{
+		"type": r.Type,
+	}
+	switch r.Type {
+	case Serial:
+		(*query)["authority_id"] = r.AuthorityId
+		(*query)["serial"] = r.Serial
+		break
+	case KeyId:
+		(*query)["key_id"] = r.KeyId
+		break
+	case PublicKey:
+		(*query)["public_key"] = r.PublicKey
+		break
+	}
+
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now()
+	}
+
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+
+	_, err = coll.UpdateOne(
+		db,
+		query,
+		&bson.M{
+			"$set": &bson.M{
+				"user_id":    r.UserId,
+				"comment":    r.Comment,
+				"key_id":     r.KeyId,
+				"public_key": r.PublicKey,
+				"serial":     r.Serial,
+				"expires":    r.Expires,
+			},
+			"$setOnInsert": &bson.M{
+				"_id":       primitive.NewObjectID(),
+				"timestamp": r.Timestamp,
+			},
+		},
+		opts,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,293 @@
+package revocation
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/device"
+	"github.com/pritunl/pritunl-zero/ssh"
+)
+
+func Get(db *database.Database, revokeId primitive.ObjectID) (
+	revoke *Revocation, err error) {
+
+	coll := db.SshRevocations()
+	revoke = &Revocation{}
+
+	err = coll.FindOneId(revokeId, revoke)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database) (revokes []*Revocation, err error) {
+	coll := db.SshRevocations()
+	revokes = []*Revocation{}
+
+	cursor, err := coll.Find(db, &bson.M{}, &options.FindOptions{
+		Sort: &bson.D{
+			{"timestamp", -1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		revoke := &Revocation{}
+		err = cursor.Decode(revoke)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		revokes = append(revokes, revoke)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAuthorities(db *database.Database,
+	authrIds []primitive.ObjectID) (revokes []*Revocation, err error) {
+
+	coll := db.SshRevocations()
+	revokes = []*Revocation{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"$and": []*bson.M{
+			&bson.M{
+				"$or": []*bson.M{
+					&bson.M{
+						"authority_id": &bson.M{
+							"$in": authrIds,
+						},
+					},
+					&bson.M{
+						"type": &bson.M{
+							"$ne": Serial,
+						},
+					},
+				},
+			},
+			&bson.M{
+				"$or": []*bson.M{
+					&bson.M{
+						"expires": time.Time{},
+					},
+					&bson.M{
+						"expires": &bson.M{
+							"$gt": time.Now(),
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		revoke := &Revocation{}
+		err = cursor.Decode(revoke)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		revokes = append(revokes, revoke)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func IsRevoked(db *database.Database, authrId primitive.ObjectID,
+	serial, keyId, publicKey string) (revoked bool, err error) {
+
+	coll := db.SshRevocations()
+
+	count, err := coll.CountDocuments(db, &bson.M{
+		"$and": []*bson.M{
+			&bson.M{
+				"$or": []*bson.M{
+					&bson.M{
+						"type":         Serial,
+						"authority_id": authrId,
+						"serial":       serial,
+					},
+					&bson.M{
+						"type":   KeyId,
+						"key_id": keyId,
+					},
+					&bson.M{
+						"type":       PublicKey,
+						"public_key": strings.TrimSpace(publicKey),
+					},
+				},
+			},
+			&bson.M{
+				"$or": []*bson.M{
+					&bson.M{
+						"expires": time.Time{},
+					},
+					&bson.M{
+						"expires": &bson.M{
+							"$gt": time.Now(),
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	revoked = count > 0
+
+	return
+}
+
+func RevokeUser(db *database.Database, userId primitive.ObjectID,
+	comment string) (err error) {
+
+	coll := db.SshCertificates()
+	now := time.Now()
+
+	cursor, err := coll.Find(db, &bson.M{
+		"user_id": userId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		cert := &ssh.Certificate{}
+		err = cursor.Decode(cert)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		for i, info := range cert.CertificatesInfo {
+			if info == nil || i >= len(cert.AuthorityIds) ||
+				!info.Expires.After(now) {
+
+				continue
+			}
+
+			revoke := &Revocation{
+				Type:        Serial,
+				AuthorityId: cert.AuthorityIds[i],
+				UserId:      userId,
+				Serial:      info.Serial,
+				Comment:     comment,
+				Timestamp:   now,
+				Expires:     info.Expires,
+			}
+
+			err = revoke.Upsert(db)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RevokeDevice(db *database.Database, devc *device.Device,
+	comment string) (err error) {
+
+	if devc.SshPublicKey == "" {
+		return
+	}
+
+	revoke := &Revocation{
+		Type:      PublicKey,
+		UserId:    devc.User,
+		PublicKey: devc.SshPublicKey,
+		Comment:   comment,
+	}
+
+	errData, err := revoke.Validate(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	err = revoke.Upsert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, revokeId primitive.ObjectID) (
+	err error) {
+
+	coll := db.SshRevocations()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": revokeId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveExpired(db *database.Database) (err error) {
+	coll := db.SshRevocations()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"expires": &bson.M{
+			"$gt": time.Time{},
+			"$lt": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"time"
 
+	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-zero/agent"
 	"github.com/pritunl/pritunl-zero/database"
@@ -25,6 +26,7 @@ type Session struct {
 	Timestamp  time.Time          `bson:"timestamp" json:"timestamp"`
 	LastActive time.Time          `bson:"last_active" json:"last_active"`
 	Removed    bool               `bson:"removed" json:"removed"`
+	StepUp     bool               `bson:"step_up" json:"step_up"`
 	Agent      *agent.Agent       `bson:"agent" json:"agent"`
 	user       *user.User         `bson:"-" json:"-"`
 }
@@ -112,6 +114,24 @@ func (s *Session) Update(db *database.Database) (err error) {
 	return
 }
 
+func (s *Session) SetStepUp(db *database.Database) (err error) {
+	coll := db.Sessions()
+
+	err = coll.UpdateId(s.Id, &bson.M{
+		"$set": &bson.M{
+			"step_up": true,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	s.StepUp = true
+
+	return
+}
+
 func (s *Session) Remove(db *database.Database) (err error) {
 	err = Remove(db, s.Id)
 	if err != nil {
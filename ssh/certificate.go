@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
@@ -17,10 +18,12 @@ import (
 )
 
 type Info struct {
-	Serial     string    `bson:"serial" json:"serial"`
-	Expires    time.Time `bson:"expires" json:"expires"`
-	Principals []string  `bson:"principals" json:"principals"`
-	Extensions []string  `bson:"extensions" json:"extensions"`
+	Serial          string    `bson:"serial" json:"serial"`
+	Expires         time.Time `bson:"expires" json:"expires"`
+	Lifetime        int       `bson:"lifetime" json:"lifetime"`
+	Principals      []string  `bson:"principals" json:"principals"`
+	Extensions      []string  `bson:"extensions" json:"extensions"`
+	CriticalOptions []string  `bson:"critical_options" json:"critical_options"`
 }
 
 type Host struct {
@@ -42,6 +45,7 @@ type Certificate struct {
 	Certificates           []string             `bson:"certificates" json:"-"`
 	CertificatesInfo       []*Info              `bson:"certificates_info" json:"certificates_info"`
 	Agent                  *agent.Agent         `bson:"agent" json:"agent"`
+	ApproverId             primitive.ObjectID   `bson:"approver_id,omitempty" json:"approver_id"`
 }
 
 func (c *Certificate) Commit(db *database.Database) (err error) {
@@ -94,6 +98,24 @@ func GetCertificate(db *database.Database, certId primitive.ObjectID) (
 	return
 }
 
+func GetCertificateSerial(db *database.Database,
+	authrId primitive.ObjectID, serial string) (cert *Certificate, err error) {
+
+	coll := db.SshCertificates()
+	cert = &Certificate{}
+
+	err = coll.FindOne(db, &bson.M{
+		"authority_ids":            authrId,
+		"certificates_info.serial": serial,
+	}).Decode(cert)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func GetCertificates(db *database.Database, userId primitive.ObjectID,
 	page, pageCount int64) (certs []*Certificate, count int64, err error) {
 
@@ -151,8 +173,8 @@ func GetCertificates(db *database.Database, userId primitive.ObjectID,
 }
 
 func NewCertificate(db *database.Database, authrs []*authority.Authority,
-	usr *user.User, agnt *agent.Agent, pubKey string) (cert *Certificate,
-	err error) {
+	usr *user.User, agnt *agent.Agent, pubKey string, ttl int) (
+	cert *Certificate, err error) {
 
 	cert = &Certificate{
 		Id:                     primitive.NewObjectID(),
@@ -172,7 +194,8 @@ func NewCertificate(db *database.Database, authrs []*authority.Authority,
 			continue
 		}
 
-		crt, certStr, e := authr.CreateCertificate(db, usr, pubKey)
+		crt, certStr, e := authr.CreateCertificate(
+			db, usr, agnt, pubKey, ttl)
 		if e != nil {
 			err = e
 			return
@@ -183,31 +206,34 @@ func NewCertificate(db *database.Database, authrs []*authority.Authority,
 		}
 
 		info := &Info{
-			Expires:    time.Unix(int64(crt.ValidBefore), 0),
-			Serial:     fmt.Sprintf("%d", crt.Serial),
-			Principals: crt.ValidPrincipals,
-			Extensions: []string{},
+			Expires:         time.Unix(int64(crt.ValidBefore), 0),
+			Lifetime:        authr.GetExpire(usr, ttl),
+			Serial:          fmt.Sprintf("%d", crt.Serial),
+			Principals:      crt.ValidPrincipals,
+			Extensions:      []string{},
+			CriticalOptions: []string{},
 		}
 
 		for permission := range crt.Permissions.Extensions {
 			info.Extensions = append(info.Extensions, permission)
 		}
+		sort.Strings(info.Extensions)
 
-		certAuthr := authr.GetCertAuthority()
-		if certAuthr != "" {
-			cert.CertificateAuthorities = append(
-				cert.CertificateAuthorities,
-				certAuthr,
-			)
+		for option, value := range crt.Permissions.CriticalOptions {
+			info.CriticalOptions = append(info.CriticalOptions,
+				fmt.Sprintf("%s=%s", option, value))
 		}
+		sort.Strings(info.CriticalOptions)
 
-		certAuthr = authr.GetBastionCertAuthority()
-		if certAuthr != "" {
-			cert.CertificateAuthorities = append(
-				cert.CertificateAuthorities,
-				certAuthr,
-			)
-		}
+		cert.CertificateAuthorities = append(
+			cert.CertificateAuthorities,
+			authr.GetCertAuthority()...,
+		)
+
+		cert.CertificateAuthorities = append(
+			cert.CertificateAuthorities,
+			authr.GetBastionCertAuthority()...,
+		)
 
 		matches, e := authr.GetMatches()
 		if e != nil {
@@ -4,4 +4,9 @@ const (
 	Approved    = "approved"
 	Unavailable = "unavailable"
 	Denied      = "denied"
+	Pending     = "pending"
+	Issuing     = "issuing"
+
+	Forward     = "forward"
+	Interactive = "interactive"
 )
@@ -12,11 +12,13 @@ import (
 	"github.com/pritunl/pritunl-zero/authority"
 	"github.com/pritunl/pritunl-zero/database"
 	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/host"
 	"github.com/pritunl/pritunl-zero/settings"
 )
 
-func NewHostCertificate(db *database.Database, hostname string, port int,
-	tokens []string, r *http.Request, pubKey string) (
+func NewHostCertificate(db *database.Database, hostname string,
+	aliases []string, port int, tokens []string, r *http.Request,
+	pubKey string) (
 	cert *Certificate, errData *errortypes.ErrorData, err error) {
 
 	pubKey = strings.TrimSpace(pubKey)
@@ -35,6 +37,13 @@ func NewHostCertificate(db *database.Database, hostname string, port int,
 		return
 	}
 
+	if len(aliases) > authority.MaxHostAliases {
+		err = errortypes.ParseError{
+			errors.New("ssh: Too many host aliases"),
+		}
+		return
+	}
+
 	agnt, err := agent.Parse(db, r)
 	if err != nil {
 		return
@@ -63,12 +72,17 @@ func NewHostCertificate(db *database.Database, hostname string, port int,
 		return
 	}
 
+	domains := []string{}
+
 	for _, authr := range authrs {
-		if !authr.HostnameValidate(hostname, port, pubKey) {
+		validateErr := authr.HostnameValidate(hostname, port, pubKey)
+		if validateErr != nil {
+			errData = validateErr
 			continue
 		}
 
-		crt, certStr, e := authr.CreateHostCertificate(db, hostname, pubKey)
+		crt, certStr, e := authr.CreateHostCertificate(db, hostname,
+			aliases, pubKey)
 		if e != nil {
 			err = e
 			return
@@ -88,22 +102,37 @@ func NewHostCertificate(db *database.Database, hostname string, port int,
 		cert.AuthorityIds = append(cert.AuthorityIds, authr.Id)
 		cert.Certificates = append(cert.Certificates, certStr)
 		cert.CertificatesInfo = append(cert.CertificatesInfo, info)
+		domains = append(domains, authr.HostDomain)
 	}
 
 	if len(cert.Certificates) == 0 {
-		errData = &errortypes.ErrorData{
-			Error:   "certificate_unavailable",
-			Message: "No certificates are available",
+		if errData == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "certificate_unavailable",
+				Message: "No certificates are available",
+			}
 		}
 		return
 	}
 
+	errData = nil
+
 	err = cert.Insert(db)
 	if err != nil {
 		err = database.ParseError(err)
 		return
 	}
 
+	for i, authrId := range cert.AuthorityIds {
+		info := cert.CertificatesInfo[i]
+
+		err = host.Record(db, hostname, authrId, domains[i], pubKey, agnt,
+			cert.Id, info.Serial, info.Principals, info.Expires)
+		if err != nil {
+			return
+		}
+	}
+
 	return
 }
 
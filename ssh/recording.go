@@ -0,0 +1,74 @@
+package ssh
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/database"
+)
+
+type RecordingChunk struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SessionId primitive.ObjectID `bson:"session_id" json:"session_id"`
+	UserId    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"`
+	Index     int                `bson:"index" json:"index"`
+	Data      string             `bson:"data" json:"-"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+}
+
+func (r *RecordingChunk) Insert(db *database.Database) (err error) {
+	coll := db.SshRecordings()
+
+	if r.Id.IsZero() {
+		r.Id = primitive.NewObjectID()
+	}
+
+	_, err = coll.InsertOne(db, r)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetRecording(db *database.Database, sessId primitive.ObjectID) (
+	chunks []*RecordingChunk, err error) {
+
+	coll := db.SshRecordings()
+	chunks = []*RecordingChunk{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"session_id": sessId,
+	}, &options.FindOptions{
+		Sort: &bson.D{
+			{"index", 1},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		chunk := &RecordingChunk{}
+		err = cursor.Decode(chunk)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
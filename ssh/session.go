@@ -0,0 +1,175 @@
+package ssh
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+type Session struct {
+	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type          string             `bson:"type" json:"type"`
+	AuthorityId   primitive.ObjectID `bson:"authority_id" json:"authority_id"`
+	CertificateId primitive.ObjectID `bson:"certificate_id,omitempty" json:"certificate_id"`
+	UserId        primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"`
+	KeyId         string             `bson:"key_id" json:"key_id"`
+	Serial        string             `bson:"serial" json:"serial"`
+	SourceAddress string             `bson:"source_address" json:"source_address"`
+	TargetHost    string             `bson:"target_host" json:"target_host"`
+	TargetPort    int                `bson:"target_port" json:"target_port"`
+	Start         time.Time          `bson:"start" json:"start"`
+	End           time.Time          `bson:"end" json:"end"`
+	BytesSent     int64              `bson:"bytes_sent" json:"bytes_sent"`
+	BytesReceived int64              `bson:"bytes_received" json:"bytes_received"`
+	Recorded      bool               `bson:"recorded" json:"recorded"`
+}
+
+func (s *Session) Commit(db *database.Database) (err error) {
+	coll := db.SshSessions()
+
+	err = coll.Commit(s.Id, s)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *Session) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.SshSessions()
+
+	err = coll.CommitFields(s.Id, s, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *Session) Insert(db *database.Database) (err error) {
+	coll := db.SshSessions()
+
+	_, err = coll.InsertOne(db, s)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func NewSession(db *database.Database, typ string,
+	authrId primitive.ObjectID, keyId, serial, sourceAddr,
+	targetHost string, targetPort int) (sess *Session, err error) {
+
+	sess = &Session{
+		Id:            primitive.NewObjectID(),
+		Type:          typ,
+		AuthorityId:   authrId,
+		KeyId:         keyId,
+		Serial:        serial,
+		SourceAddress: sourceAddr,
+		TargetHost:    targetHost,
+		TargetPort:    targetPort,
+		Start:         time.Now(),
+	}
+
+	cert, err := GetCertificateSerial(db, authrId, serial)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); !ok {
+			return
+		}
+		err = nil
+	} else {
+		sess.CertificateId = cert.Id
+		sess.UserId = cert.UserId
+	}
+
+	err = sess.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetSession(db *database.Database, userId,
+	sessId primitive.ObjectID) (sess *Session, err error) {
+
+	coll := db.SshSessions()
+	sess = &Session{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":     sessId,
+		"user_id": userId,
+	}).Decode(sess)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetSessions(db *database.Database, userId primitive.ObjectID,
+	page, pageCount int64) (sessions []*Session, count int64, err error) {
+
+	coll := db.SshSessions()
+	sessions = []*Session{}
+
+	count, err = coll.CountDocuments(db, &bson.M{
+		"user_id": userId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	opts := options.FindOptions{
+		Sort: &bson.D{
+			{"start", -1},
+		},
+	}
+
+	if pageCount != 0 {
+		page = utils.Min64(page, count/pageCount)
+		skip := utils.Min64(page*pageCount, count)
+		opts.Skip = &skip
+		opts.Limit = &pageCount
+	}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"user_id": userId,
+	}, &opts)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		sess := &Session{}
+		err = cursor.Decode(sess)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		sessions = append(sessions, sess)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,174 @@
+package sshlogin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/ssh"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+func bastionHost(proxyHost string) string {
+	host := strings.SplitN(proxyHost, "@", 2)
+	host = strings.SplitN(host[len(host)-1], ":", 2)
+	return host[0]
+}
+
+func replaceSection(path, section string, perm os.FileMode) (err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			err = &errortypes.ReadError{
+				errors.Wrapf(err, "sshlogin: Failed to read '%s'", path),
+			}
+			return
+		}
+		err = nil
+	}
+
+	output := []string{}
+	skip := false
+
+	for _, line := range strings.Split(string(data), "\n") {
+		switch strings.TrimSpace(line) {
+		case SectionStart:
+			skip = true
+			continue
+		case SectionEnd:
+			skip = false
+			continue
+		}
+
+		if !skip {
+			output = append(output, line)
+		}
+	}
+
+	content := strings.TrimRight(strings.Join(output, "\n"), "\n")
+	if section != "" {
+		if content != "" {
+			content += "\n\n"
+		}
+		content += SectionStart + "\n" + section + SectionEnd
+	}
+	content += "\n"
+
+	err = utils.ExistsMkdir(filepath.Dir(path), 0700)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path, content, perm)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func WriteCertificates(keyPath string, certs []string) (
+	certPaths []string, err error) {
+
+	stalePaths, err := filepath.Glob(keyPath + "-cert-*.pub")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sshlogin: Failed to list certificates"),
+		}
+		return
+	}
+
+	for _, pth := range stalePaths {
+		err = os.Remove(pth)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrapf(err, "sshlogin: Failed to remove '%s'", pth),
+			}
+			return
+		}
+	}
+
+	certPaths = []string{}
+	for i, cert := range certs {
+		certPath := keyPath + "-cert.pub"
+		if i > 0 {
+			certPath = fmt.Sprintf("%s-cert-%d.pub", keyPath, i)
+		}
+
+		err = utils.CreateWrite(certPath,
+			strings.TrimSpace(cert)+"\n", 0644)
+		if err != nil {
+			return
+		}
+
+		certPaths = append(certPaths, certPath)
+	}
+
+	return
+}
+
+func WriteSshConfig(sshDir string, hosts []*ssh.Host,
+	certPaths []string) (err error) {
+
+	section := ""
+	certFiles := ""
+
+	for _, certPath := range certPaths {
+		certFiles += fmt.Sprintf("\tCertificateFile \"%s\"\n", certPath)
+	}
+
+	for _, hst := range hosts {
+		if hst.ProxyHost != "" &&
+			(hst.StrictBastionChecking || certFiles != "") {
+
+			section += fmt.Sprintf("Host %s\n", bastionHost(hst.ProxyHost))
+			if hst.StrictBastionChecking {
+				section += "\tStrictHostKeyChecking yes\n"
+			}
+			section += certFiles
+		}
+
+		matches := []string{}
+		for _, match := range hst.Matches {
+			matches = append(matches, strings.Fields(match)...)
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		section += fmt.Sprintf("Host %s\n", strings.Join(matches, " "))
+		if hst.ProxyHost != "" {
+			section += fmt.Sprintf("\tProxyJump %s\n", hst.ProxyHost)
+		}
+		if hst.StrictHostChecking {
+			section += "\tStrictHostKeyChecking yes\n"
+		}
+		section += certFiles
+	}
+
+	err = replaceSection(filepath.Join(sshDir, "config"), section, 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func WriteKnownHosts(sshDir string, authorities []string) (err error) {
+	section := ""
+	for _, authr := range authorities {
+		section += strings.TrimSpace(authr) + "\n"
+	}
+
+	err = replaceSection(
+		filepath.Join(sshDir, "known_hosts"), section, 0644)
+	if err != nil {
+		return
+	}
+
+	return
+}
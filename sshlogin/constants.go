@@ -0,0 +1,21 @@
+package sshlogin
+
+import (
+	"time"
+)
+
+const (
+	SectionStart   = "# pritunl-zero start"
+	SectionEnd     = "# pritunl-zero end"
+	RequestTimeout = 45 * time.Second
+	RetryDelay     = 3 * time.Second
+	MaxRetries     = 10
+)
+
+var (
+	DefaultKeys = []string{
+		"id_ed25519",
+		"id_ecdsa",
+		"id_rsa",
+	}
+)
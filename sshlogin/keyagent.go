@@ -0,0 +1,134 @@
+package sshlogin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func parsePrivateKey(keyPath string) (key interface{}, err error) {
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sshlogin: Failed to read private key"),
+		}
+		return
+	}
+
+	key, err = ssh.ParseRawPrivateKey(data)
+	if err == nil {
+		return
+	}
+
+	if _, ok := err.(*ssh.PassphraseMissingError); !ok {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sshlogin: Failed to parse private key"),
+		}
+		return
+	}
+
+	fmt.Printf("Enter passphrase for %s: ", keyPath)
+	pass, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Println("")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sshlogin: Failed to read passphrase"),
+		}
+		return
+	}
+
+	key, err = ssh.ParseRawPrivateKeyWithPassphrase(data, pass)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sshlogin: Failed to decrypt private key"),
+		}
+		return
+	}
+
+	return
+}
+
+func ParseCertificate(certStr string) (cert *ssh.Certificate, err error) {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certStr))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sshlogin: Failed to parse certificate"),
+		}
+		return
+	}
+
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		err = &errortypes.ParseError{
+			errors.New("sshlogin: Invalid certificate"),
+		}
+		return
+	}
+
+	return
+}
+
+func AddAgent(keyPath string, certStrs []string) (err error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		err = &errortypes.RequestError{
+			errors.New("sshlogin: SSH_AUTH_SOCK not set, ssh-agent " +
+				"not running"),
+		}
+		return
+	}
+
+	key, err := parsePrivateKey(keyPath)
+	if err != nil {
+		return
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "sshlogin: Failed to connect to ssh-agent"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	client := agent.NewClient(conn)
+
+	for _, certStr := range certStrs {
+		cert, e := ParseCertificate(certStr)
+		if e != nil {
+			err = e
+			return
+		}
+
+		lifetime := time.Until(time.Unix(int64(cert.ValidBefore), 0))
+		if lifetime <= 0 {
+			continue
+		}
+
+		err = client.Add(agent.AddedKey{
+			PrivateKey:   key,
+			Certificate:  cert,
+			Comment:      cert.KeyId,
+			LifetimeSecs: uint32(lifetime.Seconds()),
+		})
+		if err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(err, "sshlogin: Failed to add certificate "+
+					"to ssh-agent"),
+			}
+			return
+		}
+	}
+
+	return
+}
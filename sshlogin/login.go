@@ -0,0 +1,344 @@
+package sshlogin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/ssh"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+type challengeData struct {
+	Token     string `json:"token"`
+	PublicKey string `json:"public_key,omitempty"`
+	Ttl       int    `json:"ttl,omitempty"`
+}
+
+type certificateData struct {
+	Token                  string      `json:"token"`
+	Certificates           []string    `json:"certificates"`
+	CertificateAuthorities []string    `json:"certificate_authorities"`
+	Hosts                  []*ssh.Host `json:"hosts"`
+}
+
+type errorData struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+type Login struct {
+	Server    string
+	KeyPath   string
+	Ttl       int
+	Agent     bool
+	NoBrowser bool
+	SshDir    string
+	client    *http.Client
+}
+
+func (l *Login) init() (err error) {
+	l.Server = strings.TrimRight(strings.TrimSpace(l.Server), "/")
+	if l.Server == "" {
+		err = &errortypes.ParseError{
+			errors.New("sshlogin: Missing server"),
+		}
+		return
+	}
+
+	if !strings.HasPrefix(l.Server, "https://") &&
+		!strings.HasPrefix(l.Server, "http://") {
+
+		l.Server = "https://" + l.Server
+	}
+
+	if l.SshDir == "" {
+		home, e := os.UserHomeDir()
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "sshlogin: Failed to get home directory"),
+			}
+			return
+		}
+		l.SshDir = filepath.Join(home, ".ssh")
+	}
+
+	if l.KeyPath == "" {
+		for _, name := range DefaultKeys {
+			pth := filepath.Join(l.SshDir, name)
+
+			exists, e := utils.ExistsFile(pth + ".pub")
+			if e != nil {
+				err = e
+				return
+			}
+
+			if exists {
+				l.KeyPath = pth
+				break
+			}
+		}
+
+		if l.KeyPath == "" {
+			err = &errortypes.NotFoundError{
+				errors.New("sshlogin: No ssh key found, create one " +
+					"with ssh-keygen"),
+			}
+			return
+		}
+	}
+
+	l.KeyPath = strings.TrimSuffix(l.KeyPath, ".pub")
+
+	l.client = &http.Client{
+		Timeout: RequestTimeout,
+	}
+
+	return
+}
+
+func (l *Login) request(method, pth string, input interface{}) (
+	status int, data []byte, err error) {
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sshlogin: Failed to marshal request"),
+		}
+		return
+	}
+
+	req, err := http.NewRequest(method, l.Server+pth, bytes.NewBuffer(body))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "sshlogin: Failed to create request"),
+		}
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "sshlogin: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	status = resp.StatusCode
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sshlogin: Failed to read response"),
+		}
+		return
+	}
+
+	return
+}
+
+func (l *Login) challenge(pubKey string) (token string, err error) {
+	status, data, err := l.request("POST", "/ssh/challenge", &challengeData{
+		PublicKey: pubKey,
+		Ttl:       l.Ttl,
+	})
+	if err != nil {
+		return
+	}
+
+	if status != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("sshlogin: Challenge request bad status %d", status),
+		}
+		return
+	}
+
+	chal := &challengeData{}
+	err = json.Unmarshal(data, chal)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sshlogin: Failed to parse challenge"),
+		}
+		return
+	}
+
+	token = chal.Token
+
+	return
+}
+
+func (l *Login) wait(token string) (certData *certificateData, err error) {
+	retries := 0
+
+	for {
+		status, data, e := l.request("PUT", "/ssh/challenge",
+			&challengeData{
+				Token: token,
+			})
+		if e != nil {
+			retries += 1
+			if retries > MaxRetries {
+				err = e
+				return
+			}
+
+			time.Sleep(RetryDelay)
+			continue
+		}
+		retries = 0
+
+		switch status {
+		case 200:
+			certData = &certificateData{}
+			err = json.Unmarshal(data, certData)
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "sshlogin: Failed to parse certificate"),
+				}
+				return
+			}
+			return
+		case 205:
+			continue
+		case 401:
+			err = &errortypes.AuthenticationError{
+				errors.New("sshlogin: Certificate request denied"),
+			}
+			return
+		case 404:
+			err = &errortypes.NotFoundError{
+				errors.New("sshlogin: Certificate request expired"),
+			}
+			return
+		case 412:
+			errData := &errorData{}
+			json.Unmarshal(data, errData)
+
+			err = &errortypes.RequestError{
+				errors.Newf("sshlogin: %s", errData.Message),
+			}
+			return
+		default:
+			err = &errortypes.RequestError{
+				errors.Newf("sshlogin: Certificate request bad status %d",
+					status),
+			}
+			return
+		}
+	}
+}
+
+func (l *Login) openBrowser(link string) {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+		break
+	case "linux":
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return
+		}
+		cmd = exec.Command("xdg-open", link)
+		break
+	default:
+		return
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("sshlogin: Failed to open browser")
+		return
+	}
+
+	go cmd.Wait()
+}
+
+func (l *Login) Run() (err error) {
+	err = l.init()
+	if err != nil {
+		return
+	}
+
+	pubKeyData, err := ioutil.ReadFile(l.KeyPath + ".pub")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sshlogin: Failed to read ssh public key"),
+		}
+		return
+	}
+	pubKey := strings.TrimSpace(string(pubKeyData))
+
+	token, err := l.challenge(pubKey)
+	if err != nil {
+		return
+	}
+
+	link := fmt.Sprintf("%s/ssh?ssh-token=%s", l.Server,
+		url.QueryEscape(token))
+
+	fmt.Printf("Open the link below to approve the certificate "+
+		"request:\n\n  %s\n\n", link)
+
+	if !l.NoBrowser {
+		l.openBrowser(link)
+	}
+
+	fmt.Println("Waiting for approval...")
+
+	certData, err := l.wait(token)
+	if err != nil {
+		return
+	}
+
+	if len(certData.Certificates) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("sshlogin: No certificates issued"),
+		}
+		return
+	}
+
+	certPaths, err := WriteCertificates(l.KeyPath, certData.Certificates)
+	if err != nil {
+		return
+	}
+
+	err = WriteSshConfig(l.SshDir, certData.Hosts, certPaths)
+	if err != nil {
+		return
+	}
+
+	err = WriteKnownHosts(l.SshDir, certData.CertificateAuthorities)
+	if err != nil {
+		return
+	}
+
+	if l.Agent {
+		err = AddAgent(l.KeyPath, certData.Certificates)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, certPath := range certPaths {
+		fmt.Printf("Certificate written to %s\n", certPath)
+	}
+
+	return
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/event"
+)
+
+var authorityRetire = &Task{
+	Name:    "authority_retire",
+	Hours:   AllHours,
+	Mins:    []int{20},
+	Handler: authorityRetireHandler,
+}
+
+func authorityRetireHandler(db *database.Database) (err error) {
+	authrs, err := authority.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	changed := false
+	for _, authr := range authrs {
+		retired := authr.RetireKeys()
+		if len(retired) == 0 {
+			continue
+		}
+
+		err = authr.CommitFields(db, set.NewSet("rotated_keys"))
+		if err != nil {
+			return
+		}
+		changed = true
+
+		for _, key := range retired {
+			logrus.WithFields(logrus.Fields{
+				"authority_id":   authr.Id.Hex(),
+				"authority_name": authr.Name,
+				"key_alg":        key.KeyAlg,
+				"rotated":        key.Rotated,
+			}).Info("task: Retired authority key")
+		}
+	}
+
+	if changed {
+		event.PublishDispatch(db, "authority.change")
+	}
+
+	return
+}
+
+func init() {
+	register(authorityRetire)
+}
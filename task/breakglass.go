@@ -0,0 +1,48 @@
+package task
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-zero/breakglass"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/event"
+)
+
+var breakGlassLock = &Task{
+	Name:    "break_glass_lock",
+	Hours:   AllHours,
+	Mins:    AllMins,
+	Handler: breakGlassLockHandler,
+}
+
+func breakGlassLockHandler(db *database.Database) (err error) {
+	accounts, err := breakglass.GetExpired(db)
+	if err != nil {
+		return
+	}
+
+	if len(accounts) == 0 {
+		return
+	}
+
+	for _, acct := range accounts {
+		err = acct.Lock(db)
+		if err != nil {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"breakglass_id": acct.Id.Hex(),
+			"name":          acct.Name,
+			"user_id":       acct.UserId.Hex(),
+		}).Warn("task: Break-glass account access expired and locked")
+	}
+
+	event.PublishDispatch(db, "breakglass.change")
+	event.PublishDispatch(db, "user.change")
+
+	return
+}
+
+func init() {
+	register(breakGlassLock)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/pritunl/pritunl-zero/device"
 	"github.com/pritunl/pritunl-zero/errortypes"
 	"github.com/pritunl/pritunl-zero/event"
+	"github.com/pritunl/pritunl-zero/revocation"
 	"github.com/pritunl/pritunl-zero/secondary"
 	"github.com/pritunl/pritunl-zero/settings"
 	"github.com/pritunl/pritunl-zero/u2flib"
@@ -106,6 +107,12 @@ func deviceDelete(c *gin.Context) {
 		return
 	}
 
+	devc, err := device.GetUser(db, devcId, usr.Id)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	count, err := device.Count(db, usr.Id)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -127,6 +134,12 @@ func deviceDelete(c *gin.Context) {
 		return
 	}
 
+	err = revocation.RevokeDevice(db, devc, "Device deleted")
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	count, err = device.Count(db, usr.Id)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -143,6 +156,13 @@ func deviceDelete(c *gin.Context) {
 			}
 		}
 
+		err = revocation.RevokeUser(
+			db, usr.Id, "All authentication devices removed")
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
 		err = audit.New(
 			db,
 			c.Request,
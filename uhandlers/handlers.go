@@ -75,6 +75,10 @@ func Register(engine *gin.Engine) {
 	dbGroup.POST("/ssh/challenge", sshChallengePost)
 	dbGroup.PUT("/ssh/challenge", sshChallengePut)
 	dbGroup.POST("/ssh/host", sshHostPost)
+	csrfGroup.POST("/ssh/machine", sshMachinePost)
+	csrfGroup.GET("/ssh/approval", approvalsGet)
+	csrfGroup.PUT("/ssh/approval/:approval_id", approvalPut)
+	csrfGroup.DELETE("/ssh/approval/:approval_id", approvalDelete)
 
 	engine.GET("/robots.txt", middlewear.RobotsGet)
 
@@ -0,0 +1,104 @@
+package uhandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-zero/audit"
+	"github.com/pritunl/pritunl-zero/authorizer"
+	"github.com/pritunl/pritunl-zero/challenge"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/utils"
+)
+
+type sshMachineData struct {
+	PublicKey string `json:"public_key"`
+	Ttl       int    `json:"ttl"`
+}
+
+func sshMachinePost(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	data := &sshMachineData{}
+
+	if !authr.IsApi() {
+		utils.AbortWithStatus(c, 401)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if data.Ttl < 0 {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	cert, errData, err := challenge.IssueMachine(
+		db, usr, c.Request, data.PublicKey, data.Ttl)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		err = audit.New(
+			db,
+			c.Request,
+			usr.Id,
+			audit.SshDeny,
+			audit.Fields{
+				"ssh_key": data.PublicKey,
+				"machine": true,
+				"error":   errData.Error,
+				"message": errData.Message,
+			},
+		)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		c.JSON(400, errData)
+		return
+	}
+
+	lifetime := 0
+	for _, info := range cert.CertificatesInfo {
+		if info.Lifetime > lifetime {
+			lifetime = info.Lifetime
+		}
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.SshApprove,
+		audit.Fields{
+			"ssh_key":       cert.PubKey,
+			"ttl_requested": data.Ttl,
+			"lifetime":      lifetime,
+			"machine":       true,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	resp := &sshCertificateData{
+		Hosts:                  cert.Hosts,
+		Certificates:           cert.Certificates,
+		CertificateAuthorities: cert.CertificateAuthorities,
+	}
+
+	c.JSON(200, resp)
+}
@@ -25,6 +25,7 @@ var (
 type sshValidateData struct {
 	Token     string `json:"token"`
 	PublicKey string `json:"public_key,omitempty"`
+	Ttl       int    `json:"ttl,omitempty"`
 }
 
 type sshCertificateData struct {
@@ -143,13 +144,20 @@ func sshValidatePut(c *gin.Context) {
 		return
 	}
 
+	auditType := audit.SshApprove
+	if chal.State == ssh.Pending {
+		auditType = audit.SshApprovalRequest
+	}
+
 	err = audit.New(
 		db,
 		c.Request,
 		usr.Id,
-		audit.SshApprove,
+		auditType,
 		audit.Fields{
-			"ssh_key": chal.PubKey,
+			"ssh_key":       chal.PubKey,
+			"ttl_requested": chal.Ttl,
+			"lifetime":      chal.Lifetime,
 		},
 	)
 	if err != nil {
@@ -286,13 +294,20 @@ func sshSecondaryPut(c *gin.Context) {
 		return
 	}
 
+	auditType := audit.SshApprove
+	if chal.State == ssh.Pending {
+		auditType = audit.SshApprovalRequest
+	}
+
 	err = audit.New(
 		db,
 		c.Request,
 		usr.Id,
-		audit.SshApprove,
+		auditType,
 		audit.Fields{
-			"ssh_key": chal.PubKey,
+			"ssh_key":       chal.PubKey,
+			"ttl_requested": chal.Ttl,
+			"lifetime":      chal.Lifetime,
 		},
 	)
 	if err != nil {
@@ -426,13 +441,20 @@ func sshU2fSignPost(c *gin.Context) {
 		return
 	}
 
+	auditType := audit.SshApprove
+	if chal.State == ssh.Pending {
+		auditType = audit.SshApprovalRequest
+	}
+
 	err = audit.New(
 		db,
 		c.Request,
 		usr.Id,
-		audit.SshApprove,
+		auditType,
 		audit.Fields{
-			"ssh_key": chal.PubKey,
+			"ssh_key":       chal.PubKey,
+			"ttl_requested": chal.Ttl,
+			"lifetime":      chal.Lifetime,
 		},
 	)
 	if err != nil {
@@ -468,6 +490,12 @@ func sshChallengePut(c *gin.Context) {
 	}
 	token := chal.Id
 
+	err = chal.Refresh(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	sync := func() {
 		chal, err = challenge.GetChallenge(db, data.Token)
 		if err != nil {
@@ -480,6 +508,12 @@ func sshChallengePut(c *gin.Context) {
 			}
 			return
 		}
+
+		err = chal.Refresh(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
 	}
 
 	update := func() bool {
@@ -570,7 +604,12 @@ func sshChallengePost(c *gin.Context) {
 		return
 	}
 
-	chal, err := challenge.NewChallenge(db, data.PublicKey)
+	if data.Ttl < 0 {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	chal, err := challenge.NewChallenge(db, data.PublicKey, data.Ttl)
 	if err != nil {
 		switch err.(type) {
 		case *database.NotFoundError:
@@ -591,6 +630,7 @@ func sshChallengePost(c *gin.Context) {
 
 type sshHostData struct {
 	Hostname  string   `json:"hostname"`
+	Aliases   []string `json:"aliases"`
 	Port      int      `json:"port"`
 	Tokens    []string `json:"tokens"`
 	PublicKey string   `json:"public_key"`
@@ -613,7 +653,7 @@ func sshHostPost(c *gin.Context) {
 	hostname := domainRe.ReplaceAllString(data.Hostname, "")
 
 	cert, errData, err := ssh.NewHostCertificate(db, hostname,
-		data.Port, data.Tokens, c.Request, data.PublicKey)
+		data.Aliases, data.Port, data.Tokens, c.Request, data.PublicKey)
 	if err != nil {
 		switch err.(type) {
 		case *database.NotFoundError:
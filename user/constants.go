@@ -12,6 +12,9 @@ const (
 	Google   = "google"
 	OneLogin = "onelogin"
 	Okta     = "okta"
+
+	DefaultMachineTtl = 30
+	MaxMachineTtl     = 1440
 )
 
 var (
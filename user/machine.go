@@ -0,0 +1,128 @@
+package user
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/errortypes"
+)
+
+type Machine struct {
+	Authorities []primitive.ObjectID `bson:"authorities" json:"authorities"`
+	Principals  []string             `bson:"principals" json:"principals"`
+	MaxTtl      int                  `bson:"max_ttl" json:"max_ttl"`
+	Networks    []string             `bson:"networks" json:"networks"`
+}
+
+func (m *Machine) HasAuthority(authrId primitive.ObjectID) bool {
+	for _, id := range m.Authorities {
+		if id == authrId {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Machine) HasPrincipal(principal string) bool {
+	for _, prin := range m.Principals {
+		if prin == principal {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Machine) NetworkAllowed(addr string) bool {
+	if len(m.Networks) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range m.Networks {
+		_, subnet, err := net.ParseCIDR(network)
+		if err != nil {
+			continue
+		}
+
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Machine) Validate() (errData *errortypes.ErrorData) {
+	if m.Authorities == nil {
+		m.Authorities = []primitive.ObjectID{}
+	}
+
+	if len(m.Authorities) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "machine_authorities_missing",
+			Message: "Machine identity requires at least one authority",
+		}
+		return
+	}
+
+	principals := []string{}
+	principalsSet := map[string]bool{}
+	for _, principal := range m.Principals {
+		principal = strings.TrimSpace(principal)
+		if principal == "" || principalsSet[principal] {
+			continue
+		}
+		principalsSet[principal] = true
+		principals = append(principals, principal)
+	}
+	sort.Strings(principals)
+	m.Principals = principals
+
+	if len(m.Principals) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "machine_principals_missing",
+			Message: "Machine identity requires at least one principal",
+		}
+		return
+	}
+
+	if m.MaxTtl == 0 {
+		m.MaxTtl = DefaultMachineTtl
+	}
+
+	if m.MaxTtl < 1 || m.MaxTtl > MaxMachineTtl {
+		errData = &errortypes.ErrorData{
+			Error:   "machine_max_ttl_invalid",
+			Message: "Machine identity maximum TTL is invalid",
+		}
+		return
+	}
+
+	networks := []string{}
+	for _, network := range m.Networks {
+		network = strings.TrimSpace(network)
+		if network == "" {
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(network)
+		if err != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "machine_network_invalid",
+				Message: "Machine identity network is invalid",
+			}
+			return
+		}
+
+		networks = append(networks, subnet.String())
+	}
+	m.Networks = networks
+
+	return
+}
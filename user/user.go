@@ -34,6 +34,7 @@ type User struct {
 	Disabled        bool               `bson:"disabled" json:"disabled"`
 	ActiveUntil     time.Time          `bson:"active_until" json:"active_until"`
 	Permissions     []string           `bson:"permissions" json:"permissions"`
+	Machine         *Machine           `bson:"machine" json:"machine"`
 }
 
 func (u *User) Validate(db *database.Database) (
@@ -71,6 +72,17 @@ func (u *User) Validate(db *database.Database) (
 		return
 	}
 
+	if u.Type != Api {
+		u.Machine = nil
+	}
+
+	if u.Machine != nil {
+		errData = u.Machine.Validate()
+		if errData != nil {
+			return
+		}
+	}
+
 	u.Format()
 
 	return
@@ -0,0 +1,252 @@
+package validator
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-zero/agent"
+	"github.com/pritunl/pritunl-zero/authority"
+	"github.com/pritunl/pritunl-zero/database"
+	"github.com/pritunl/pritunl-zero/errortypes"
+	"github.com/pritunl/pritunl-zero/policy"
+	"github.com/pritunl/pritunl-zero/service"
+	"github.com/pritunl/pritunl-zero/user"
+)
+
+const (
+	ExplainUser      = "user"
+	ExplainService   = "service"
+	ExplainAuthority = "authority"
+)
+
+type PolicyResult struct {
+	Id       primitive.ObjectID   `json:"id"`
+	Name     string               `json:"name"`
+	Source   string               `json:"source"`
+	Disabled bool                 `json:"disabled"`
+	Passed   bool                 `json:"passed"`
+	StepUp   bool                 `json:"step_up"`
+	Rules    []*policy.RuleResult `json:"rules"`
+}
+
+type Explanation struct {
+	ctx               *policy.Context
+	stepUpPolicies    []*policy.Policy
+	Target            string             `json:"target"`
+	Allowed           bool               `json:"allowed"`
+	Error             string             `json:"error"`
+	Message           string             `json:"message"`
+	Agent             *agent.Agent       `json:"agent"`
+	Timestamp         time.Time          `json:"timestamp"`
+	Policies          []*PolicyResult    `json:"policies"`
+	StepUp            bool               `json:"step_up"`
+	DeviceSecondary   bool               `json:"device_secondary"`
+	SecondaryProvider primitive.ObjectID `json:"secondary_provider"`
+	RequireSmartCard  bool               `json:"require_smart_card"`
+	ApprovalRequired  bool               `json:"approval_required"`
+}
+
+func (e *Explanation) deny(errData *errortypes.ErrorData) {
+	if !e.Allowed {
+		return
+	}
+
+	e.Allowed = false
+	e.Error = errData.Error
+	e.Message = errData.Message
+}
+
+func (e *Explanation) evaluate(policies []*policy.Policy, source string) {
+	for _, polcy := range policies {
+		results, stepUp, errData := polcy.Evaluate(e.ctx)
+
+		e.Policies = append(e.Policies, &PolicyResult{
+			Id:       polcy.Id,
+			Name:     polcy.Name,
+			Source:   source,
+			Disabled: polcy.Disabled,
+			Passed:   errData == nil,
+			StepUp:   stepUp,
+			Rules:    results,
+		})
+
+		if errData != nil {
+			e.deny(errData)
+		} else if stepUp {
+			e.StepUp = true
+			e.stepUpPolicies = append(e.stepUpPolicies, polcy)
+		}
+	}
+}
+
+func (e *Explanation) secondary(deviceSecondary bool,
+	secondary primitive.ObjectID) {
+
+	if deviceSecondary {
+		e.DeviceSecondary = true
+	}
+
+	if !secondary.IsZero() && e.SecondaryProvider.IsZero() {
+		e.SecondaryProvider = secondary
+	}
+}
+
+func Explain(db *database.Database, usr *user.User, srvc *service.Service,
+	authr *authority.Authority, agnt *agent.Agent, now time.Time) (
+	explain *Explanation, err error) {
+
+	explain = &Explanation{
+		Target:    ExplainUser,
+		Allowed:   true,
+		Agent:     agnt,
+		Timestamp: now,
+		Policies:  []*PolicyResult{},
+	}
+
+	var target *policy.Target
+	if srvc != nil {
+		explain.Target = ExplainService
+		target = &policy.Target{
+			ServiceId: srvc.Id,
+		}
+	} else if authr != nil {
+		explain.Target = ExplainAuthority
+		target = &policy.Target{
+			AuthorityIds: []primitive.ObjectID{authr.Id},
+		}
+	}
+
+	explain.ctx = policy.NewContext(db, usr, agnt, now, target)
+
+	if !usr.ActiveUntil.IsZero() && usr.ActiveUntil.Before(now) {
+		explain.deny(&errortypes.ErrorData{
+			Error:   "user_disabled",
+			Message: "User is disabled from expired active time",
+		})
+	}
+
+	if usr.Disabled {
+		explain.deny(&errortypes.ErrorData{
+			Error:   "user_disabled",
+			Message: "User is disabled",
+		})
+	}
+
+	switch explain.Target {
+	case ExplainService:
+		usrRoles := set.NewSet()
+		for _, role := range usr.Roles {
+			usrRoles.Add(role)
+		}
+
+		roleMatch := false
+		for _, role := range srvc.Roles {
+			if usrRoles.Contains(role) {
+				roleMatch = true
+				break
+			}
+		}
+
+		if !roleMatch {
+			explain.deny(&errortypes.ErrorData{
+				Error:   "service_unauthorized",
+				Message: "Not authorized for service",
+			})
+		}
+
+		servicePolicies, e := policy.GetService(db, srvc.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		rolePolicies, e := policy.GetRoles(db, usr.Roles)
+		if e != nil {
+			err = e
+			return
+		}
+
+		explain.evaluate(servicePolicies, "service")
+		explain.evaluate(rolePolicies, "role")
+
+		for _, polcy := range append(servicePolicies, rolePolicies...) {
+			if polcy.Disabled {
+				continue
+			}
+
+			explain.secondary(polcy.ProxyDeviceSecondary,
+				polcy.ProxySecondary)
+		}
+
+		stepUpDevice, stepUpProvider, stepUpAvailable := stepUpSecondary(
+			explain.stepUpPolicies)
+		if stepUpDevice {
+			explain.DeviceSecondary = true
+		}
+
+		if !stepUpProvider.IsZero() {
+			explain.SecondaryProvider = stepUpProvider
+		}
+
+		if explain.StepUp && !stepUpAvailable {
+			explain.deny(&errortypes.ErrorData{
+				Error:   "step_up_unavailable",
+				Message: "Step-up authentication has no secondary configured",
+			})
+		}
+
+		break
+	case ExplainAuthority:
+		if !authr.UserHasAccess(usr) {
+			explain.deny(&errortypes.ErrorData{
+				Error:   "authority_unauthorized",
+				Message: "Not authorized for authority",
+			})
+		}
+
+		explain.ApprovalRequired = authr.ApprovalRequired
+
+		policies, e := policy.GetAuthoritiesRoles(
+			db, []primitive.ObjectID{authr.Id}, usr.Roles)
+		if e != nil {
+			err = e
+			return
+		}
+
+		explain.evaluate(policies, "authority")
+
+		for _, polcy := range policies {
+			if polcy.Disabled {
+				continue
+			}
+
+			explain.secondary(polcy.AuthorityDeviceSecondary,
+				polcy.AuthoritySecondary)
+
+			if polcy.AuthorityRequireSmartCard {
+				explain.RequireSmartCard = true
+			}
+		}
+
+		break
+	default:
+		policies, e := policy.GetRoles(db, usr.Roles)
+		if e != nil {
+			err = e
+			return
+		}
+
+		explain.evaluate(policies, "role")
+
+		for _, polcy := range policies {
+			if polcy.Disabled {
+				continue
+			}
+
+			explain.secondary(polcy.UserDeviceSecondary, polcy.UserSecondary)
+		}
+	}
+
+	return
+}
@@ -11,10 +11,31 @@ import (
 	"github.com/pritunl/pritunl-zero/errortypes"
 	"github.com/pritunl/pritunl-zero/event"
 	"github.com/pritunl/pritunl-zero/policy"
+	"github.com/pritunl/pritunl-zero/revocation"
 	"github.com/pritunl/pritunl-zero/service"
 	"github.com/pritunl/pritunl-zero/user"
 )
 
+func stepUpSecondary(policies []*policy.Policy) (deviceAuth bool,
+	secProvider primitive.ObjectID, available bool) {
+
+	for _, polcy := range policies {
+		if polcy.StepUpDeviceSecondary {
+			deviceAuth = true
+			available = true
+		}
+
+		if !polcy.StepUpSecondary.IsZero() {
+			available = true
+			if secProvider.IsZero() {
+				secProvider = polcy.StepUpSecondary
+			}
+		}
+	}
+
+	return
+}
+
 func ValidateAdmin(db *database.Database, usr *user.User,
 	isApi bool, r *http.Request) (deviceAuth bool,
 	secProvider primitive.ObjectID, errAudit audit.Fields,
@@ -28,6 +49,11 @@ func ValidateAdmin(db *database.Database, usr *user.User,
 			return
 		}
 
+		err = revocation.RevokeUser(db, usr.Id, "User active time expired")
+		if err != nil {
+			return
+		}
+
 		event.PublishDispatch(db, "user.change")
 
 		errAudit = audit.Fields{
@@ -73,7 +99,7 @@ func ValidateAdmin(db *database.Database, usr *user.User,
 		}
 
 		for _, polcy := range policies {
-			errData, err = polcy.ValidateUser(db, usr, r)
+			_, errData, err = polcy.ValidateUser(db, usr, r, nil)
 			if err != nil || errData != nil {
 				return
 			}
@@ -109,6 +135,11 @@ func ValidateUser(db *database.Database, usr *user.User,
 			return
 		}
 
+		err = revocation.RevokeUser(db, usr.Id, "User active time expired")
+		if err != nil {
+			return
+		}
+
 		event.PublishDispatch(db, "user.change")
 
 		errAudit = audit.Fields{
@@ -142,7 +173,7 @@ func ValidateUser(db *database.Database, usr *user.User,
 		}
 
 		for _, polcy := range policies {
-			errData, err = polcy.ValidateUser(db, usr, r)
+			_, errData, err = polcy.ValidateUser(db, usr, r, nil)
 			if err != nil || errData != nil {
 				return
 			}
@@ -168,7 +199,7 @@ func ValidateUser(db *database.Database, usr *user.User,
 
 func ValidateProxy(db *database.Database, usr *user.User,
 	isApi bool, srvc *service.Service, r *http.Request) (
-	deviceAuth bool, secProvider primitive.ObjectID,
+	deviceAuth bool, secProvider primitive.ObjectID, stepUp bool,
 	errAudit audit.Fields, errData *errortypes.ErrorData, err error) {
 
 	if !usr.ActiveUntil.IsZero() && usr.ActiveUntil.Before(time.Now()) {
@@ -179,6 +210,11 @@ func ValidateProxy(db *database.Database, usr *user.User,
 			return
 		}
 
+		err = revocation.RevokeUser(db, usr.Id, "User active time expired")
+		if err != nil {
+			return
+		}
+
 		event.PublishDispatch(db, "user.change")
 
 		errAudit = audit.Fields{
@@ -230,6 +266,11 @@ func ValidateProxy(db *database.Database, usr *user.User,
 	}
 
 	if !isApi {
+		target := &policy.Target{
+			ServiceId: srvc.Id,
+		}
+		stepUpPolicies := []*policy.Policy{}
+
 		policies, e := policy.GetService(db, srvc.Id)
 		if e != nil {
 			err = e
@@ -237,10 +278,16 @@ func ValidateProxy(db *database.Database, usr *user.User,
 		}
 
 		for _, polcy := range policies {
-			errData, err = polcy.ValidateUser(db, usr, r)
+			polcyStepUp := false
+			polcyStepUp, errData, err = polcy.ValidateUser(
+				db, usr, r, target)
 			if err != nil || errData != nil {
 				return
 			}
+
+			if polcyStepUp {
+				stepUpPolicies = append(stepUpPolicies, polcy)
+			}
 		}
 
 		for _, polcy := range policies {
@@ -263,10 +310,16 @@ func ValidateProxy(db *database.Database, usr *user.User,
 		}
 
 		for _, polcy := range policies {
-			errData, err = polcy.ValidateUser(db, usr, r)
+			polcyStepUp := false
+			polcyStepUp, errData, err = polcy.ValidateUser(
+				db, usr, r, target)
 			if err != nil || errData != nil {
 				return
 			}
+
+			if polcyStepUp {
+				stepUpPolicies = append(stepUpPolicies, polcy)
+			}
 		}
 
 		for _, polcy := range policies {
@@ -278,6 +331,30 @@ func ValidateProxy(db *database.Database, usr *user.User,
 				secProvider = polcy.ProxySecondary
 			}
 		}
+
+		stepUpDevice, stepUpProvider, stepUpAvailable := stepUpSecondary(
+			stepUpPolicies)
+		if stepUpDevice {
+			deviceAuth = true
+		}
+
+		if !stepUpProvider.IsZero() {
+			secProvider = stepUpProvider
+		}
+
+		stepUp = len(stepUpPolicies) != 0
+
+		if stepUp && !stepUpAvailable {
+			errAudit = audit.Fields{
+				"error":   "step_up_unavailable",
+				"message": "Step-up authentication has no secondary configured",
+			}
+			errData = &errortypes.ErrorData{
+				Error:   "unauthorized",
+				Message: "Not authorized",
+			}
+			return
+		}
 	}
 
 	return